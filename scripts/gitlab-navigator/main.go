@@ -1,18 +1,26 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // ── Netrc parsing ───────────────────────────────────────────
@@ -145,6 +153,17 @@ func discoverHosts(filter string) []string {
 
 // ── HTTP client (PRIVATE-TOKEN) ─────────────────────────────
 
+// apiError carries the HTTP status so callers can explain specific
+// failures (e.g. 405/406/409 from the merge endpoint).
+type apiError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("API returned HTTP %d: %s", e.StatusCode, string(e.Body))
+}
+
 type apiClient struct {
 	baseURL  string
 	login    string
@@ -160,6 +179,10 @@ func newClient(host string, entry netrcEntry) *apiClient {
 }
 
 func (c *apiClient) doRequest(method, fullURL string, body io.Reader) (*http.Response, error) {
+	return c.doRequestType(method, fullURL, "application/x-www-form-urlencoded", body)
+}
+
+func (c *apiClient) doRequestType(method, fullURL, contentType string, body io.Reader) (*http.Response, error) {
 	req, err := http.NewRequest(method, fullURL, body)
 	if err != nil {
 		return nil, err
@@ -167,7 +190,7 @@ func (c *apiClient) doRequest(method, fullURL string, body io.Reader) (*http.Res
 	req.Header.Set("PRIVATE-TOKEN", c.password)
 	req.Header.Set("Accept", "application/json")
 	if method != "GET" {
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Content-Type", contentType)
 	}
 	return http.DefaultClient.Do(req)
 }
@@ -184,7 +207,7 @@ func (c *apiClient) get(endpoint string, params url.Values) (json.RawMessage, er
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API returned HTTP %d: %s", resp.StatusCode, string(body))
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: body}
 	}
 	return json.RawMessage(body), nil
 }
@@ -201,25 +224,201 @@ func (c *apiClient) getWithHeaders(endpoint string, params url.Values) (json.Raw
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		return nil, nil, fmt.Errorf("API returned HTTP %d: %s", resp.StatusCode, string(body))
+		return nil, nil, &apiError{StatusCode: resp.StatusCode, Body: body}
 	}
 	return json.RawMessage(body), resp.Header, nil
 }
 
 func (c *apiClient) post(endpoint string, form url.Values) (json.RawMessage, error) {
+	return c.send("POST", endpoint, form)
+}
+
+func (c *apiClient) put(endpoint string, form url.Values) (json.RawMessage, error) {
+	return c.send("PUT", endpoint, form)
+}
+
+func (c *apiClient) delete(endpoint string, params url.Values) error {
+	u := c.baseURL + "/api/v4" + endpoint
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	resp, err := c.doRequest("DELETE", u, nil)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return &apiError{StatusCode: resp.StatusCode, Body: body}
+	}
+	return nil
+}
+
+func (c *apiClient) send(method, endpoint string, form url.Values) (json.RawMessage, error) {
 	u := c.baseURL + "/api/v4" + endpoint
-	resp, err := c.doRequest("POST", u, bytes.NewBufferString(form.Encode()))
+	resp, err := c.doRequest(method, u, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: body}
+	}
+	return json.RawMessage(body), nil
+}
+
+// sendJSON is send with a JSON body, for endpoints whose parameters are
+// arrays of objects (pipeline variables, commit actions) that form
+// encoding cannot express reliably.
+func (c *apiClient) sendJSON(method, endpoint string, payload any) (json.RawMessage, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.doRequestType(method, c.baseURL+"/api/v4"+endpoint, "application/json", bytes.NewReader(b))
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API returned HTTP %d: %s", resp.StatusCode, string(body))
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: body}
 	}
 	return json.RawMessage(body), nil
 }
 
+// getAll follows X-Next-Page until every page of a list endpoint has
+// been read.
+func (c *apiClient) getAll(endpoint string, params url.Values) ([]any, error) {
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = v
+	}
+	if q.Get("per_page") == "" {
+		q.Set("per_page", "100")
+	}
+	var all []any
+	for page := "1"; page != ""; {
+		q.Set("page", page)
+		data, hdr, err := c.getWithHeaders(endpoint, q)
+		if err != nil {
+			return nil, err
+		}
+		var items []any
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", endpoint, err)
+		}
+		all = append(all, items...)
+		page = hdr.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+// getRaw fetches a non-JSON body (raw diffs, job traces, artifacts).
+func (c *apiClient) getRaw(endpoint string, params url.Values) ([]byte, error) {
+	u := c.baseURL + "/api/v4" + endpoint
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: body}
+	}
+	return body, nil
+}
+
+// errGraphQLUnavailable marks instances where /api/graphql is missing or
+// disabled; callers fall back to REST.
+var errGraphQLUnavailable = errors.New("GraphQL API unavailable")
+
+// graphqlError carries the "errors" array of a GraphQL response.
+type graphqlError struct {
+	Messages []string
+}
+
+func (e *graphqlError) Error() string {
+	return "GraphQL: " + strings.Join(e.Messages, "; ")
+}
+
+// graphql runs one query against /api/graphql. When the response has
+// both data and errors, the partial data is returned with the error.
+func (c *apiClient) graphql(query string, vars map[string]any) (map[string]any, error) {
+	b, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.doRequestType("POST", c.baseURL+"/api/graphql", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == 404 || resp.StatusCode == 501 {
+		return nil, fmt.Errorf("%w: HTTP %d", errGraphQLUnavailable, resp.StatusCode)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: body}
+	}
+	var out struct {
+		Data   map[string]any `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("%w: %v", errGraphQLUnavailable, err)
+	}
+	if len(out.Errors) > 0 {
+		ge := &graphqlError{}
+		for _, e := range out.Errors {
+			ge.Messages = append(ge.Messages, e.Message)
+		}
+		return out.Data, ge
+	}
+	return out.Data, nil
+}
+
+// graphqlAll follows cursor pagination on the connection at path (e.g.
+// "currentUser", "starredProjects"). The query must take an $after
+// variable and select pageInfo { hasNextPage endCursor } and nodes.
+func (c *apiClient) graphqlAll(query string, vars map[string]any, path ...string) ([]any, error) {
+	v := map[string]any{}
+	for k, val := range vars {
+		v[k] = val
+	}
+	var all []any
+	for {
+		data, err := c.graphql(query, v)
+		if err != nil {
+			return nil, err
+		}
+		conn := data
+		for _, key := range path {
+			conn = jsonMap(conn, key)
+		}
+		if conn == nil {
+			return nil, fmt.Errorf("GraphQL: no connection at %s", strings.Join(path, "."))
+		}
+		all = append(all, jsonArr(conn, "nodes")...)
+		page := jsonMap(conn, "pageInfo")
+		if page["hasNextPage"] != true || jsonStr(page, "endCursor") == "" {
+			return all, nil
+		}
+		v["after"] = jsonStr(page, "endCursor")
+	}
+}
+
 // ── Output helpers ──────────────────────────────────────────
 
 func die(format string, args ...any) {
@@ -247,6 +446,13 @@ func jsonStr(m map[string]any, key string) string {
 	return ""
 }
 
+func jsonNum(m map[string]any, key string) float64 {
+	if f, ok := m[key].(float64); ok {
+		return f
+	}
+	return 0
+}
+
 func jsonMap(m map[string]any, key string) map[string]any {
 	if v, ok := m[key]; ok && v != nil {
 		if mm, ok := v.(map[string]any); ok {
@@ -419,6 +625,55 @@ func cmdStarred(c *apiClient, args []string) {
 	}
 }
 
+const starredActivityQuery = `query($after: String) {
+  currentUser {
+    starredProjects(first: 100, after: $after) {
+      nodes {
+        fullPath
+        lastActivityAt
+        repository { rootRef }
+        mergeRequests(state: opened) { count }
+      }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+// starredProjects returns every starred project as REST-shaped maps. One
+// paginated GraphQL query also yields open MR counts ("open_mrs");
+// the REST fallback omits them.
+func starredProjects(c *apiClient) ([]map[string]any, error) {
+	var out []map[string]any
+	nodes, err := c.graphqlAll(starredActivityQuery, nil, "currentUser", "starredProjects")
+	if err != nil && !errors.Is(err, errGraphQLUnavailable) {
+		return nil, err
+	}
+	if err == nil {
+		for _, n := range nodes {
+			nm := asMap(n)
+			out = append(out, map[string]any{
+				"path_with_namespace": jsonStr(nm, "fullPath"),
+				"last_activity_at":    jsonStr(nm, "lastActivityAt"),
+				"default_branch":      jsonStr(jsonMap(nm, "repository"), "rootRef"),
+				"open_mrs":            jsonNum(jsonMap(nm, "mergeRequests"), "count"),
+			})
+		}
+		return out, nil
+	}
+	items, err := c.getAll("/projects", url.Values{
+		"starred": {"true"}, "order_by": {"updated_at"}, "sort": {"desc"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		if pm := asMap(it); pm != nil {
+			out = append(out, pm)
+		}
+	}
+	return out, nil
+}
+
 func cmdStarredActivity(c *apiClient, args []string) {
 	days := 7
 	if len(args) > 0 {
@@ -427,29 +682,30 @@ func cmdStarredActivity(c *apiClient, args []string) {
 			days = 7
 		}
 	}
-	after := time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour).Format(time.RFC3339)
-	data, err := c.get("/projects", url.Values{
-		"starred": {"true"}, "per_page": {"100"}, "order_by": {"updated_at"}, "sort": {"desc"},
-	})
+	after := time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour)
+	projects, err := starredProjects(c)
 	if err != nil {
 		die("%s", err)
 	}
-	var projects []any
-	json.Unmarshal(data, &projects)
 	var active []map[string]any
-	for _, p := range projects {
-		pm := asMap(p)
-		if pm == nil {
-			continue
-		}
-		if jsonStr(pm, "last_activity_at") > after {
+	for _, pm := range projects {
+		// GraphQL and REST format timestamps differently, so compare times.
+		if t, err := time.Parse(time.RFC3339, jsonStr(pm, "last_activity_at")); err == nil && t.After(after) {
 			active = append(active, pm)
 		}
 	}
+	sort.SliceStable(active, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339, jsonStr(active[i], "last_activity_at"))
+		tj, _ := time.Parse(time.RFC3339, jsonStr(active[j], "last_activity_at"))
+		return ti.After(tj)
+	})
 	fmt.Printf("Starred projects with activity in last %d days (%d projects):\n\n", days, len(active))
 	for _, pm := range active {
 		fmt.Printf("%s\n", jsonStr(pm, "path_with_namespace"))
 		fmt.Printf("  Last activity: %s\n", jsonStr(pm, "last_activity_at"))
+		if _, ok := pm["open_mrs"]; ok {
+			fmt.Printf("  Open MRs: %s\n", jsonStr(pm, "open_mrs"))
+		}
 		fmt.Printf("  Default branch: %s\n\n", strOr(jsonStr(pm, "default_branch"), "main"))
 	}
 }
@@ -751,15 +1007,105 @@ func cmdProjectMRs(c *apiClient, args []string) {
 	}
 }
 
-func cmdMR(c *apiClient, args []string) {
-	if len(args) < 2 {
-		die("Usage: mr <project-id-or-path> <mr-iid>")
+const mrSummaryQuery = `query($path: ID!, $iid: String!) {
+  project(fullPath: $path) {
+    mergeRequest(iid: $iid) {
+      iid title state draft webUrl description
+      createdAt updatedAt mergedAt
+      sourceBranch targetBranch
+      author { username }
+      mergeUser { username }
+      assignees { nodes { username } }
+      reviewers { nodes { username } }
+      labels { nodes { title } }
+      milestone { title }
+      mergeStatusEnum
+      conflicts
+      diffStatsSummary { fileCount }
+      approved
+      approvedBy { nodes { username } }
+      headPipeline { id status path }
+    }
+  }
+}`
+
+// mrSummaryGraphQL fetches an MR with its approvals and head pipeline in
+// one query. Projects given by numeric ID have no fullPath to query by.
+func mrSummaryGraphQL(c *apiClient, project, mrIID string) (map[string]any, error) {
+	if _, err := strconv.Atoi(project); err == nil {
+		return nil, fmt.Errorf("%w: numeric project ID", errGraphQLUnavailable)
 	}
-	encoded := url.PathEscape(args[0])
-	mrIID := args[1]
+	data, err := c.graphql(mrSummaryQuery, map[string]any{"path": project, "iid": mrIID})
+	if err != nil {
+		return nil, err
+	}
+	m := jsonMap(jsonMap(data, "project"), "mergeRequest")
+	if m == nil {
+		return nil, fmt.Errorf("merge request !%s not found in %s", mrIID, project)
+	}
+	usernames := func(key string) []string {
+		var names []string
+		for _, n := range jsonArr(jsonMap(m, key), "nodes") {
+			names = append(names, jsonStr(asMap(n), "username"))
+		}
+		return names
+	}
+	var labels []string
+	for _, n := range jsonArr(jsonMap(m, "labels"), "nodes") {
+		labels = append(labels, jsonStr(asMap(n), "title"))
+	}
+	iid, _ := strconv.Atoi(jsonStr(m, "iid")) // GraphQL IIDs are strings
+	out := map[string]any{
+		"iid":           iid,
+		"title":         jsonStr(m, "title"),
+		"state":         jsonStr(m, "state"),
+		"author":        jsonStr(jsonMap(m, "author"), "username"),
+		"assignees":     usernames("assignees"),
+		"reviewers":     usernames("reviewers"),
+		"source_branch": jsonStr(m, "sourceBranch"),
+		"target_branch": jsonStr(m, "targetBranch"),
+		"created":       jsonStr(m, "createdAt"),
+		"updated":       jsonStr(m, "updatedAt"),
+		"merged_by":     nil,
+		"merged_at":     nil,
+		"labels":        labels,
+		"milestone":     nil,
+		"draft":         m["draft"],
+		"merge_status":  strOr(strings.ToLower(jsonStr(m, "mergeStatusEnum")), "unknown"),
+		"has_conflicts": m["conflicts"],
+		"changes_count": strOr(jsonStr(jsonMap(m, "diffStatsSummary"), "fileCount"), "unknown"),
+		"web_url":       jsonStr(m, "webUrl"),
+		"description":   strOr(jsonStr(m, "description"), "none"),
+		"approved":      m["approved"],
+		"approved_by":   usernames("approvedBy"),
+		"pipeline":      nil,
+	}
+	if mu := jsonMap(m, "mergeUser"); mu != nil && jsonStr(m, "mergedAt") != "" {
+		out["merged_by"] = jsonStr(mu, "username")
+	}
+	if jsonStr(m, "mergedAt") != "" {
+		out["merged_at"] = jsonStr(m, "mergedAt")
+	}
+	if ms := jsonMap(m, "milestone"); ms != nil {
+		out["milestone"] = jsonStr(ms, "title")
+	}
+	if hp := jsonMap(m, "headPipeline"); hp != nil {
+		id := jsonStr(hp, "id")
+		out["pipeline"] = map[string]any{
+			"id":      id[strings.LastIndex(id, "/")+1:], // gid://gitlab/Ci::Pipeline/123
+			"status":  strings.ToLower(jsonStr(hp, "status")),
+			"web_url": strings.TrimSuffix(c.baseURL, "/") + jsonStr(hp, "path"),
+		}
+	}
+	return out, nil
+}
+
+// mrSummaryREST is the two-call REST equivalent of mrSummaryGraphQL.
+func mrSummaryREST(c *apiClient, project, mrIID string) (map[string]any, error) {
+	encoded := url.PathEscape(project)
 	data, err := c.get("/projects/"+encoded+"/merge_requests/"+mrIID, nil)
 	if err != nil {
-		die("%s", err)
+		return nil, err
 	}
 	var m map[string]any
 	json.Unmarshal(data, &m)
@@ -801,6 +1147,9 @@ func cmdMR(c *apiClient, args []string) {
 		"changes_count": strOr(jsonStr(m, "changes_count"), "unknown"),
 		"web_url":       jsonStr(m, "web_url"),
 		"description":   strOr(jsonStr(m, "description"), "none"),
+		"approved":      nil,
+		"approved_by":   []string{},
+		"pipeline":      nil,
 	}
 	if mergedBy != nil {
 		out["merged_by"] = jsonStr(mergedBy, "username")
@@ -811,36 +1160,78 @@ func cmdMR(c *apiClient, args []string) {
 	if milestone != nil {
 		out["milestone"] = jsonStr(milestone, "title")
 	}
+	if hp := jsonMap(m, "head_pipeline"); hp != nil {
+		out["pipeline"] = map[string]any{
+			"id":      jsonStr(hp, "id"),
+			"status":  jsonStr(hp, "status"),
+			"web_url": jsonStr(hp, "web_url"),
+		}
+	}
+	if data, err := c.get("/projects/"+encoded+"/merge_requests/"+mrIID+"/approvals", nil); err == nil {
+		var ap map[string]any
+		json.Unmarshal(data, &ap)
+		var by []string
+		for _, a := range jsonArr(ap, "approved_by") {
+			by = append(by, jsonStr(jsonMap(asMap(a), "user"), "username"))
+		}
+		out["approved"] = ap["approved"]
+		out["approved_by"] = by
+	}
+	return out, nil
+}
+
+// cmdMR prints an MR with its approvals and head pipeline: one GraphQL
+// query, or REST when GraphQL is unavailable.
+func cmdMR(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: mr <project-id-or-path> <mr-iid>")
+	}
+	out, err := mrSummaryGraphQL(c, args[0], args[1])
+	if errors.Is(err, errGraphQLUnavailable) {
+		out, err = mrSummaryREST(c, args[0], args[1])
+	}
+	if err != nil {
+		die("%s", err)
+	}
 	printJSON(out)
 }
 
+// cmdMRChanges lists an MR's changed files, or with --stat / --diff a
+// diffstat or full unified diffs. Diffs GitLab truncates are re-read from
+// the raw diffs endpoint when the instance has it.
+//
+//	<host> mr-changes <project> <iid> [--stat | --diff]
 func cmdMRChanges(c *apiClient, args []string) {
 	if len(args) < 2 {
-		die("Usage: mr-changes <project-id-or-path> <mr-iid>")
+		die("Usage: mr-changes <project-id-or-path> <mr-iid> [--stat | --diff]")
 	}
 	encoded := url.PathEscape(args[0])
 	mrIID := args[1]
-	data, err := c.get("/projects/"+encoded+"/merge_requests/"+mrIID+"/changes", nil)
+	fs := flag.NewFlagSet("mr-changes", flag.ExitOnError)
+	stat := fs.Bool("stat", false, "print a diffstat summary")
+	diff := fs.Bool("diff", false, "print full unified diffs")
+	_ = fs.Parse(args[2:])
+	mode := diffMode(*stat, *diff)
+
+	diffs, err := mrDiffs(c, encoded, mrIID)
 	if err != nil {
 		die("%s", err)
 	}
-	var m map[string]any
-	json.Unmarshal(data, &m)
-	for _, change := range jsonArr(m, "changes") {
-		cm := asMap(change)
-		if cm == nil {
-			continue
-		}
-		status := "modified"
-		if jsonStr(cm, "new_file") == "true" {
-			status = "added"
-		} else if jsonStr(cm, "deleted_file") == "true" {
-			status = "deleted"
-		} else if jsonStr(cm, "renamed_file") == "true" {
-			status = fmt.Sprintf("renamed from %s", jsonStr(cm, "old_path"))
+	var raw map[string]string
+	if mode != "files" {
+		for _, d := range diffs {
+			if diffTruncated(d) {
+				text, err := c.getRaw("/projects/"+encoded+"/merge_requests/"+mrIID+"/raw_diffs", nil)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "warning: some diffs are truncated and raw_diffs is unavailable: %v\n", err)
+				} else {
+					raw = splitRawDiff(string(text))
+				}
+				break
+			}
 		}
-		fmt.Printf("%s (%s)\n\n", jsonStr(cm, "new_path"), status)
 	}
+	renderDiffs(os.Stdout, diffs, raw, mode)
 }
 
 func cmdMyIssues(c *apiClient, args []string) {
@@ -1050,6 +1441,18 @@ func cmdPipeline(c *apiClient, args []string) {
 			strOr(jsonStr(jm, "duration"), "0"),
 			runnerDesc)
 	}
+
+	// Test summary is best-effort: older instances lack the endpoint and
+	// most pipelines have no JUnit reports.
+	if sum, err := c.get("/projects/"+encoded+"/pipelines/"+pipelineID+"/test_report_summary", nil); err == nil {
+		var sm map[string]any
+		json.Unmarshal(sum, &sm)
+		if total := jsonMap(sm, "total"); jsonNum(total, "count") > 0 {
+			fmt.Printf("\nTests: %s total, %s failed, %s errors, %s skipped (details: test-report %s %s)\n",
+				jsonStr(total, "count"), jsonStr(total, "failed"), jsonStr(total, "error"),
+				jsonStr(total, "skipped"), args[0], pipelineID)
+		}
+	}
 }
 
 func cmdBranches(c *apiClient, args []string) {
@@ -1406,72 +1809,5753 @@ func cmdRegistries(c *apiClient, args []string) {
 	}
 }
 
-// ── Help ────────────────────────────────────────────────────
-
-func printHelp() {
-	fmt.Println(`Usage: gitlab-navigator <host> <command> [args...]
-
-Discovery:
-  discover [substring]                             Find GitLab hosts in ~/.netrc
-
-Query commands (host = hostname or unique substring from ~/.netrc):
-  <host> whoami                                    Current user
-  <host> test                                      Test connection
-
-Activity & starred:
-  <host> starred [limit]                           Starred projects
-  <host> starred-activity [days] [limit]           Starred projects with recent activity
-  <host> events [limit]                            Your recent activity feed
-  <host> project-events <project> [limit]          Project activity
+// ── Write commands ──────────────────────────────────────────
 
-Projects:
-  <host> projects [limit]                          Your projects (by membership)
-  <host> project-info <project>                    Project details + statistics
-  <host> create-project <name> [path] [visibility] [namespace-id]
-                                                    Create a new project
+// readBody returns text from exactly one of: a literal flag, a file, or
+// stdin. Precedence is literal, then file, then stdin.
+func readBody(literal, file string, stdin bool) string {
+	switch {
+	case literal != "":
+		return literal
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			die("read %s: %v", file, err)
+		}
+		return string(data)
+	case stdin:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			die("read stdin: %v", err)
+		}
+		return string(data)
+	default:
+		return ""
+	}
+}
 
-Merge requests:
-  <host> my-mrs [state] [limit]                    MRs assigned to you
-  <host> mr-review [state] [limit]                 MRs awaiting your review
-  <host> project-mrs <project> [state] [limit]     MRs in a project
-  <host> mr <project> <iid>                        MR details
-  <host> mr-changes <project> <iid>                MR changed files
+func splitCSV(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if t := strings.TrimSpace(part); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
 
-Issues:
-  <host> my-issues [state] [limit]                 Issues assigned to you
-  <host> project-issues <project> [state] [limit]  Issues in a project
-  <host> issue <project> <iid>                     Issue details
+// flagsSet reports which flags were given explicitly, so update commands
+// can tell "not passed" from "passed empty" (e.g. clearing reviewers).
+func flagsSet(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
 
-Pipelines:
-  <host> pipelines <project> [limit]               Recent pipelines
-  <host> pipeline <project> <id>                   Pipeline details + jobs
+// userIDs resolves usernames (with or without a leading @) to user IDs.
+func userIDs(c *apiClient, usernames []string) ([]string, error) {
+	var ids []string
+	for _, name := range usernames {
+		name = strings.TrimPrefix(name, "@")
+		data, err := c.get("/users", url.Values{"username": {name}})
+		if err != nil {
+			return nil, err
+		}
+		var users []any
+		json.Unmarshal(data, &users)
+		if len(users) == 0 {
+			return nil, fmt.Errorf("unknown user %q", name)
+		}
+		ids = append(ids, jsonStr(asMap(users[0]), "id"))
+	}
+	return ids, nil
+}
 
-Code:
-  <host> branches <project> [limit]                List branches
-  <host> commits <project> [ref] [limit]           Recent commits
-  <host> tree <project> [path] [ref]               Directory listing
-  <host> file <project> <path> [ref]               Read file content
+// setUserIDs writes ids as a form array; an empty list sends the single
+// "0" GitLab uses to mean "unassign everyone".
+func setUserIDs(form url.Values, key string, ids []string) {
+	if len(ids) == 0 {
+		form.Set(key, "0")
+		return
+	}
+	for _, id := range ids {
+		form.Add(key+"[]", id)
+	}
+}
 
-Groups:
-  <host> groups [limit]                            Your groups
-  <host> group-projects <group> [limit]            Projects in a group
+var draftPrefixRe = regexp.MustCompile(`(?i)^\s*(draft:|\[draft\]|\(draft\)|wip:|\[wip\])\s*`)
 
-Search:
-  <host> search <query> [scope] [limit]            Global search
-  <host> project-search <project> <query> [scope]  Project-scoped search
+// draftTitle adds or removes the title prefix GitLab uses to mark drafts.
+func draftTitle(title string, draft bool) string {
+	title = draftPrefixRe.ReplaceAllString(title, "")
+	if draft {
+		return "Draft: " + title
+	}
+	return title
+}
 
-Registry:
-  <host> registries <project>                      Container registry repos
+func currentGitBranch() string {
+	out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	b := strings.TrimSpace(string(out))
+	if b == "HEAD" {
+		return ""
+	}
+	return b
+}
 
-Search scopes: projects, issues, merge_requests, milestones, blobs
-Project refs: use ID (numeric) or URL-encoded path (group%2Fproject)`)
+func printMRResult(verb string, data json.RawMessage) {
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("!%s %s: %s\n", jsonStr(m, "iid"), verb, jsonStr(m, "title"))
+	fmt.Printf("  %s -> %s  [%s]\n", jsonStr(m, "source_branch"), jsonStr(m, "target_branch"), jsonStr(m, "state"))
+	fmt.Printf("  %s\n", jsonStr(m, "web_url"))
 }
 
-// ── Main ────────────────────────────────────────────────────
+// cmdMRCreate opens a merge request.
+//
+//	<host> mr-create <project> --title "..." [--source b] [--target main]
+//	                 [--desc "..." | --desc-file path | --desc-stdin]
+//	                 [--reviewers a,b] [--assignees a,b] [--labels x,y]
+//	                 [--draft] [--remove-source-branch] [--squash]
+//
+// --source defaults to the current git branch; --target defaults to the
+// project's default branch. A "Draft:" prefix in --title is kept as typed
+// unless --draft=false is given.
+func cmdMRCreate(c *apiClient, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		die("Usage: mr-create <project> --title \"...\" [--source b] [--target main] [flags]")
+	}
+	encoded := url.PathEscape(args[0])
+	fs := flag.NewFlagSet("mr-create", flag.ExitOnError)
+	title := fs.String("title", "", "MR title (required)")
+	source := fs.String("source", "", "source branch (default: current git branch)")
+	target := fs.String("target", "", "target branch (default: project default branch)")
+	desc := fs.String("desc", "", "description text")
+	descFile := fs.String("desc-file", "", "read description from file")
+	descStdin := fs.Bool("desc-stdin", false, "read description from stdin")
+	reviewers := fs.String("reviewers", "", "comma-separated reviewer usernames")
+	assignees := fs.String("assignees", "", "comma-separated assignee usernames")
+	labels := fs.String("labels", "", "comma-separated labels")
+	draft := fs.Bool("draft", false, "open as a draft")
+	removeSource := fs.Bool("remove-source-branch", false, "delete the source branch when merged")
+	squash := fs.Bool("squash", false, "squash commits when merged")
+	_ = fs.Parse(args[1:])
+	set := flagsSet(fs)
 
-func main() {
-	args := os.Args[1:]
-	if len(args) == 0 || args[0] == "help" {
+	if *title == "" {
+		die("mr-create: --title is required")
+	}
+	src := strOr(*source, currentGitBranch())
+	if src == "" {
+		die("mr-create: --source is required (not inside a git checkout)")
+	}
+	tgt := *target
+	if tgt == "" {
+		data, err := c.get("/projects/"+encoded, nil)
+		if err != nil {
+			die("%s", err)
+		}
+		var pm map[string]any
+		json.Unmarshal(data, &pm)
+		tgt = strOr(jsonStr(pm, "default_branch"), "main")
+	}
+
+	// A "Draft:" prefix typed into --title is kept unless --draft=false
+	// explicitly asks for a ready MR.
+	mrTitle := *title
+	if set["draft"] {
+		mrTitle = draftTitle(mrTitle, *draft)
+	}
+	form := url.Values{
+		"source_branch": {src},
+		"target_branch": {tgt},
+		"title":         {mrTitle},
+	}
+	if body := readBody(*desc, *descFile, *descStdin); body != "" {
+		form.Set("description", body)
+	}
+	if *reviewers != "" {
+		ids, err := userIDs(c, splitCSV(*reviewers))
+		if err != nil {
+			die("reviewers: %v", err)
+		}
+		setUserIDs(form, "reviewer_ids", ids)
+	}
+	if *assignees != "" {
+		ids, err := userIDs(c, splitCSV(*assignees))
+		if err != nil {
+			die("assignees: %v", err)
+		}
+		setUserIDs(form, "assignee_ids", ids)
+	}
+	if *labels != "" {
+		form.Set("labels", strings.Join(splitCSV(*labels), ","))
+	}
+	if *removeSource {
+		form.Set("remove_source_branch", "true")
+	}
+	if *squash {
+		form.Set("squash", "true")
+	}
+
+	data, err := c.post("/projects/"+encoded+"/merge_requests", form)
+	if err != nil {
+		var ae *apiError
+		if errors.As(err, &ae) && ae.StatusCode == 409 {
+			die("mr-create: an open MR already exists for %s -> %s\n%s", src, tgt, err)
+		}
+		die("mr-create: %v", err)
+	}
+	printMRResult("created", data)
+}
+
+// cmdMRUpdate edits an existing merge request. Only flags that are passed
+// are changed; --reviewers "" clears reviewers and --desc "" the
+// description.
+//
+//	<host> mr-update <project> <iid> [--title] [--target] [--desc...]
+//	                 [--reviewers a,b] [--assignees a,b] [--labels x,y]
+//	                 [--add-labels x] [--remove-labels y]
+//	                 [--draft | --ready] [--close | --reopen]
+func cmdMRUpdate(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: mr-update <project> <iid> [flags]")
+	}
+	encoded := url.PathEscape(args[0])
+	mrIID := args[1]
+	fs := flag.NewFlagSet("mr-update", flag.ExitOnError)
+	title := fs.String("title", "", "new title")
+	target := fs.String("target", "", "new target branch")
+	desc := fs.String("desc", "", "new description")
+	descFile := fs.String("desc-file", "", "read new description from file")
+	descStdin := fs.Bool("desc-stdin", false, "read new description from stdin")
+	reviewers := fs.String("reviewers", "", "replace reviewers (comma-separated usernames; empty clears)")
+	assignees := fs.String("assignees", "", "replace assignees (comma-separated usernames; empty clears)")
+	labels := fs.String("labels", "", "replace labels")
+	addLabels := fs.String("add-labels", "", "labels to add")
+	removeLabels := fs.String("remove-labels", "", "labels to remove")
+	draft := fs.Bool("draft", false, "mark as draft")
+	ready := fs.Bool("ready", false, "mark as ready (remove draft)")
+	closeMR := fs.Bool("close", false, "close the MR")
+	reopen := fs.Bool("reopen", false, "reopen the MR")
+	removeSource := fs.String("remove-source-branch", "", "true|false")
+	squash := fs.String("squash", "", "true|false")
+	_ = fs.Parse(args[2:])
+	set := flagsSet(fs)
+
+	if *draft && *ready {
+		die("mr-update: --draft and --ready are mutually exclusive")
+	}
+	if *closeMR && *reopen {
+		die("mr-update: --close and --reopen are mutually exclusive")
+	}
+
+	endpoint := "/projects/" + encoded + "/merge_requests/" + mrIID
+	form := url.Values{}
+	newTitle := *title
+	if *draft || *ready {
+		if newTitle == "" {
+			data, err := c.get(endpoint, nil)
+			if err != nil {
+				die("%s", err)
+			}
+			var m map[string]any
+			json.Unmarshal(data, &m)
+			newTitle = jsonStr(m, "title")
+		}
+		newTitle = draftTitle(newTitle, *draft)
+	}
+	if newTitle != "" {
+		form.Set("title", newTitle)
+	}
+	if *target != "" {
+		form.Set("target_branch", *target)
+	}
+	if set["desc"] || set["desc-file"] || set["desc-stdin"] {
+		form.Set("description", readBody(*desc, *descFile, *descStdin))
+	}
+	if set["reviewers"] {
+		ids, err := userIDs(c, splitCSV(*reviewers))
+		if err != nil {
+			die("reviewers: %v", err)
+		}
+		setUserIDs(form, "reviewer_ids", ids)
+	}
+	if set["assignees"] {
+		ids, err := userIDs(c, splitCSV(*assignees))
+		if err != nil {
+			die("assignees: %v", err)
+		}
+		setUserIDs(form, "assignee_ids", ids)
+	}
+	if set["labels"] {
+		form.Set("labels", strings.Join(splitCSV(*labels), ","))
+	}
+	if *addLabels != "" {
+		form.Set("add_labels", strings.Join(splitCSV(*addLabels), ","))
+	}
+	if *removeLabels != "" {
+		form.Set("remove_labels", strings.Join(splitCSV(*removeLabels), ","))
+	}
+	if *removeSource != "" {
+		form.Set("remove_source_branch", *removeSource)
+	}
+	if *squash != "" {
+		form.Set("squash", *squash)
+	}
+	if *closeMR {
+		form.Set("state_event", "close")
+	}
+	if *reopen {
+		form.Set("state_event", "reopen")
+	}
+	if len(form) == 0 {
+		die("mr-update: nothing to change")
+	}
+
+	data, err := c.put(endpoint, form)
+	if err != nil {
+		die("mr-update: %v", err)
+	}
+	printMRResult("updated", data)
+}
+
+// cmdMRApprove approves (or with unapprove=true, revokes approval of) a
+// merge request. --sha makes the approval fail if the MR head moved.
+//
+//	<host> mr-approve <project> <iid> [--sha SHA]
+//	<host> mr-unapprove <project> <iid>
+func cmdMRApprove(c *apiClient, args []string, unapprove bool) {
+	name := "mr-approve"
+	if unapprove {
+		name = "mr-unapprove"
+	}
+	if len(args) < 2 {
+		die("Usage: %s <project> <iid>", name)
+	}
+	encoded := url.PathEscape(args[0])
+	mrIID := args[1]
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	sha := fs.String("sha", "", "approve only if this is still the MR head SHA")
+	_ = fs.Parse(args[2:])
+
+	endpoint := "/projects/" + encoded + "/merge_requests/" + mrIID
+	form := url.Values{}
+	if unapprove {
+		endpoint += "/unapprove"
+	} else {
+		endpoint += "/approve"
+		if *sha != "" {
+			form.Set("sha", *sha)
+		}
+	}
+	data, err := c.post(endpoint, form)
+	if err != nil {
+		var ae *apiError
+		if errors.As(err, &ae) {
+			switch ae.StatusCode {
+			case 401:
+				die("%s: not allowed (own MR, already approved, or approval rules forbid it)\n%s", name, err)
+			case 409:
+				die("%s: SHA does not match the MR head — re-review the latest changes\n%s", name, err)
+			case 404:
+				if unapprove {
+					die("%s: you have not approved !%s\n%s", name, mrIID, err)
+				}
+			}
+		}
+		die("%s: %v", name, err)
+	}
+	if unapprove {
+		fmt.Printf("!%s approval revoked\n", mrIID)
+		return
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	var by []string
+	for _, a := range jsonArr(m, "approved_by") {
+		by = append(by, jsonStr(jsonMap(asMap(a), "user"), "username"))
+	}
+	fmt.Printf("!%s approved (approvals left: %s; approved by: %s)\n",
+		mrIID, strOr(jsonStr(m, "approvals_left"), "0"), strings.Join(by, ", "))
+}
+
+// cmdMRMerge merges a merge request, or schedules it to merge when its
+// pipeline succeeds.
+//
+//	<host> mr-merge <project> <iid> [--squash] [--remove-source-branch]
+//	                [--when-pipeline-succeeds] [--message "..."]
+//	                [--squash-message "..."] [--sha SHA]
+func cmdMRMerge(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: mr-merge <project> <iid> [flags]")
+	}
+	encoded := url.PathEscape(args[0])
+	mrIID := args[1]
+	fs := flag.NewFlagSet("mr-merge", flag.ExitOnError)
+	squash := fs.Bool("squash", false, "squash commits into one")
+	removeSource := fs.Bool("remove-source-branch", false, "delete the source branch after merge")
+	whenPipeline := fs.Bool("when-pipeline-succeeds", false, "merge automatically once the pipeline passes")
+	message := fs.String("message", "", "custom merge commit message")
+	squashMessage := fs.String("squash-message", "", "custom squash commit message")
+	sha := fs.String("sha", "", "merge only if this is still the MR head SHA")
+	_ = fs.Parse(args[2:])
+
+	form := url.Values{}
+	if *squash {
+		form.Set("squash", "true")
+	}
+	if *removeSource {
+		form.Set("should_remove_source_branch", "true")
+	}
+	if *whenPipeline {
+		// auto_merge replaced merge_when_pipeline_succeeds in 17.x; older
+		// instances ignore the unknown parameter.
+		form.Set("merge_when_pipeline_succeeds", "true")
+		form.Set("auto_merge", "true")
+	}
+	if *message != "" {
+		form.Set("merge_commit_message", *message)
+	}
+	if *squashMessage != "" {
+		form.Set("squash_commit_message", *squashMessage)
+	}
+	if *sha != "" {
+		form.Set("sha", *sha)
+	}
+
+	data, err := c.put("/projects/"+encoded+"/merge_requests/"+mrIID+"/merge", form)
+	if err != nil {
+		var ae *apiError
+		if errors.As(err, &ae) {
+			switch ae.StatusCode {
+			case 401:
+				die("mr-merge: you are not allowed to merge !%s\n%s", mrIID, err)
+			case 405:
+				die("mr-merge: !%s is not mergeable (draft, closed, conflicts, unresolved threads or missing approvals)\n%s", mrIID, err)
+			case 406:
+				die("mr-merge: branch cannot be merged (conflicts or pipeline not finished — try --when-pipeline-succeeds)\n%s", err)
+			case 409:
+				die("mr-merge: SHA does not match the MR head\n%s", err)
+			case 422:
+				die("mr-merge: merge refused (e.g. pipeline required but missing)\n%s", err)
+			}
+		}
+		die("mr-merge: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	switch {
+	case jsonStr(m, "state") == "merged":
+		fmt.Printf("!%s merged", mrIID)
+		if commit := strOr(jsonStr(m, "squash_commit_sha"), jsonStr(m, "merge_commit_sha")); commit != "" {
+			fmt.Printf(" as %s", commit)
+		}
+		fmt.Println()
+	case m["merge_when_pipeline_succeeds"] == true || jsonStr(m, "auto_merge_strategy") != "":
+		fmt.Printf("!%s will merge when the pipeline succeeds\n", mrIID)
+	default:
+		fmt.Printf("!%s merge requested (state: %s, merge_status: %s)\n",
+			mrIID, jsonStr(m, "state"), jsonStr(m, "merge_status"))
+	}
+	fmt.Printf("  %s\n", jsonStr(m, "web_url"))
+}
+
+// ── MR discussions ──────────────────────────────────────────
+
+// mrDiffs returns the per-file diff entries of an MR. It pages through
+// /diffs (GitLab 15.7+) and falls back to the older /changes payload when
+// that endpoint does not exist.
+func mrDiffs(c *apiClient, encoded, mrIID string) ([]map[string]any, error) {
+	var out []map[string]any
+	items, err := c.getAll("/projects/"+encoded+"/merge_requests/"+mrIID+"/diffs", nil)
+	if err == nil {
+		for _, it := range items {
+			if m := asMap(it); m != nil {
+				out = append(out, m)
+			}
+		}
+		return out, nil
+	}
+	var ae *apiError
+	if !errors.As(err, &ae) || ae.StatusCode != 404 {
+		return nil, err
+	}
+	data, err := c.get("/projects/"+encoded+"/merge_requests/"+mrIID+"/changes", nil)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	for _, ch := range jsonArr(m, "changes") {
+		if cm := asMap(ch); cm != nil {
+			if m["overflow"] == true {
+				cm["overflow"] = true
+			}
+			out = append(out, cm)
+		}
+	}
+	return out, nil
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// diffLineKind classifies a new-file line against a unified diff: "added"
+// (only new_line is valid), "context" (both lines), or "unchanged" when the
+// line is outside every hunk. other is the matching line on the opposite
+// side for context and unchanged lines. With old=true, line is an old-file
+// line and kind is "removed", "context" or "unchanged".
+func diffLineKind(diff string, line int, old bool) (kind string, other int) {
+	delta := 0 // new minus old, accumulated from hunks before the line
+	var oldN, newN int
+	inHunk := false
+	for _, l := range strings.Split(diff, "\n") {
+		if m := hunkHeaderRe.FindStringSubmatch(l); m != nil {
+			oldN, _ = strconv.Atoi(m[1])
+			newN, _ = strconv.Atoi(m[3])
+			if (!old && newN > line) || (old && oldN > line) {
+				break
+			}
+			delta = newN - oldN
+			inHunk = true
+			continue
+		}
+		if !inHunk || l == "" {
+			continue
+		}
+		switch l[0] {
+		case '+':
+			if !old && newN == line {
+				return "added", 0
+			}
+			newN++
+		case '-':
+			if old && oldN == line {
+				return "removed", 0
+			}
+			oldN++
+		case ' ':
+			if !old && newN == line {
+				return "context", oldN
+			}
+			if old && oldN == line {
+				return "context", newN
+			}
+			oldN++
+			newN++
+		default:
+			continue
+		}
+		delta = newN - oldN
+	}
+	if old {
+		return "unchanged", line + delta
+	}
+	return "unchanged", line - delta
+}
+
+func noteAnchor(pos map[string]any) string {
+	if pos == nil {
+		return ""
+	}
+	if l := jsonStr(pos, "new_line"); l != "" {
+		return jsonStr(pos, "new_path") + ":" + l
+	}
+	if l := jsonStr(pos, "old_line"); l != "" {
+		return jsonStr(pos, "old_path") + ":" + l + " (old)"
+	}
+	return strOr(jsonStr(pos, "new_path"), jsonStr(pos, "old_path"))
+}
+
+func indentLines(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = prefix + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cmdMRDiscussions renders an MR's review threads with file:line anchors
+// and resolved state. System notes are hidden unless --system.
+//
+//	<host> mr-discussions <project> <iid> [--unresolved] [--system]
+func cmdMRDiscussions(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: mr-discussions <project> <iid> [--unresolved] [--system]")
+	}
+	encoded := url.PathEscape(args[0])
+	mrIID := args[1]
+	fs := flag.NewFlagSet("mr-discussions", flag.ExitOnError)
+	unresolvedOnly := fs.Bool("unresolved", false, "only show unresolved threads")
+	showSystem := fs.Bool("system", false, "include system notes (pushes, label changes, ...)")
+	_ = fs.Parse(args[2:])
+
+	items, err := c.getAll("/projects/"+encoded+"/merge_requests/"+mrIID+"/discussions", nil)
+	if err != nil {
+		die("%s", err)
+	}
+	shown, open := 0, 0
+	for _, it := range items {
+		d := asMap(it)
+		notes := jsonArr(d, "notes")
+		if len(notes) == 0 {
+			continue
+		}
+		first := asMap(notes[0])
+		if first["system"] == true && !*showSystem {
+			continue
+		}
+		resolvable, resolved := false, true
+		for _, n := range notes {
+			nm := asMap(n)
+			if nm["resolvable"] == true {
+				resolvable = true
+				if nm["resolved"] != true {
+					resolved = false
+				}
+			}
+		}
+		state := ""
+		if resolvable {
+			if resolved {
+				state = "resolved"
+			} else {
+				state = "unresolved"
+				open++
+			}
+		}
+		if *unresolvedOnly && state != "unresolved" {
+			continue
+		}
+		shown++
+		header := "Discussion " + jsonStr(d, "id")
+		if state != "" {
+			header += " [" + state + "]"
+		}
+		if anchor := noteAnchor(jsonMap(first, "position")); anchor != "" {
+			header += "  " + anchor
+		}
+		fmt.Println(header)
+		for i, n := range notes {
+			nm := asMap(n)
+			prefix := "  "
+			if i > 0 {
+				prefix = "    ↳ "
+			}
+			fmt.Printf("%s@%s (%s) note %s\n", prefix,
+				jsonStr(jsonMap(nm, "author"), "username"), jsonStr(nm, "created_at"), jsonStr(nm, "id"))
+			fmt.Println(indentLines(jsonStr(nm, "body"), strings.Repeat(" ", len([]rune(prefix)))+"  "))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d thread(s) shown, %d unresolved\n", shown, open)
+}
+
+// cmdMRReply adds a note to an existing discussion thread.
+//
+//	<host> mr-reply <project> <iid> <discussion-id> [--body "..." | --body-file f | --body-stdin] [--resolve]
+func cmdMRReply(c *apiClient, args []string) {
+	if len(args) < 3 {
+		die("Usage: mr-reply <project> <iid> <discussion-id> --body \"...\" [--resolve]")
+	}
+	encoded := url.PathEscape(args[0])
+	mrIID, discussionID := args[1], args[2]
+	fs := flag.NewFlagSet("mr-reply", flag.ExitOnError)
+	body := fs.String("body", "", "reply text")
+	bodyFile := fs.String("body-file", "", "read reply from file")
+	bodyStdin := fs.Bool("body-stdin", false, "read reply from stdin")
+	resolve := fs.Bool("resolve", false, "resolve the thread after replying")
+	_ = fs.Parse(args[3:])
+
+	text := readBody(*body, *bodyFile, *bodyStdin)
+	if strings.TrimSpace(text) == "" {
+		die("mr-reply: body is empty (use --body, --body-file, or --body-stdin)")
+	}
+	endpoint := "/projects/" + encoded + "/merge_requests/" + mrIID + "/discussions/" + url.PathEscape(discussionID)
+	data, err := c.post(endpoint+"/notes", url.Values{"body": {text}})
+	if err != nil {
+		die("mr-reply: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("reply posted to %s: note %s\n", discussionID, jsonStr(m, "id"))
+	if *resolve {
+		if _, err := c.put(endpoint, url.Values{"resolved": {"true"}}); err != nil {
+			die("resolve: %v", err)
+		}
+		fmt.Printf("discussion %s resolved\n", discussionID)
+	}
+}
+
+// cmdMRResolve marks a discussion thread resolved (or unresolved).
+//
+//	<host> mr-resolve <project> <iid> <discussion-id> [--unresolve]
+func cmdMRResolve(c *apiClient, args []string) {
+	if len(args) < 3 {
+		die("Usage: mr-resolve <project> <iid> <discussion-id> [--unresolve]")
+	}
+	encoded := url.PathEscape(args[0])
+	mrIID, discussionID := args[1], args[2]
+	fs := flag.NewFlagSet("mr-resolve", flag.ExitOnError)
+	unresolve := fs.Bool("unresolve", false, "reopen the thread instead")
+	_ = fs.Parse(args[3:])
+
+	endpoint := "/projects/" + encoded + "/merge_requests/" + mrIID + "/discussions/" + url.PathEscape(discussionID)
+	if _, err := c.put(endpoint, url.Values{"resolved": {strconv.FormatBool(!*unresolve)}}); err != nil {
+		die("mr-resolve: %v", err)
+	}
+	if *unresolve {
+		fmt.Printf("discussion %s unresolved\n", discussionID)
+	} else {
+		fmt.Printf("discussion %s resolved\n", discussionID)
+	}
+}
+
+// cmdMRComment starts a new discussion on an MR. With --file and --line
+// it becomes a diff note positioned on that line using the MR's
+// diff_refs; --old anchors on a removed (old-file) line instead.
+//
+//	<host> mr-comment <project> <iid> --body "..." [--file path --line N [--old]]
+func cmdMRComment(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: mr-comment <project> <iid> --body \"...\" [--file path --line N [--old]]")
+	}
+	encoded := url.PathEscape(args[0])
+	mrIID := args[1]
+	fs := flag.NewFlagSet("mr-comment", flag.ExitOnError)
+	body := fs.String("body", "", "comment text")
+	bodyFile := fs.String("body-file", "", "read comment from file")
+	bodyStdin := fs.Bool("body-stdin", false, "read comment from stdin")
+	file := fs.String("file", "", "file path in the MR diff (new path)")
+	line := fs.Int("line", 0, "line number in the new file (old file with --old)")
+	old := fs.Bool("old", false, "--line refers to the old file (comment on a removed line)")
+	_ = fs.Parse(args[2:])
+
+	text := readBody(*body, *bodyFile, *bodyStdin)
+	if strings.TrimSpace(text) == "" {
+		die("mr-comment: body is empty (use --body, --body-file, or --body-stdin)")
+	}
+	if (*file == "") != (*line == 0) {
+		die("mr-comment: --file and --line must be given together")
+	}
+
+	mrEndpoint := "/projects/" + encoded + "/merge_requests/" + mrIID
+	form := url.Values{"body": {text}}
+	anchor := ""
+	if *file != "" {
+		data, err := c.get(mrEndpoint, nil)
+		if err != nil {
+			die("%s", err)
+		}
+		var mr map[string]any
+		json.Unmarshal(data, &mr)
+		refs := jsonMap(mr, "diff_refs")
+		if refs == nil {
+			die("mr-comment: MR has no diff_refs (is the diff still being prepared?)")
+		}
+		diffs, err := mrDiffs(c, encoded, mrIID)
+		if err != nil {
+			die("%s", err)
+		}
+		var change map[string]any
+		for _, d := range diffs {
+			if jsonStr(d, "new_path") == *file || jsonStr(d, "old_path") == *file {
+				change = d
+				break
+			}
+		}
+		if change == nil {
+			die("mr-comment: %s is not part of this MR's diff", *file)
+		}
+		// Without the hunks every line looks unchanged, which would anchor
+		// the note by guesswork; only a pure rename has no diff to read.
+		if jsonStr(change, "diff") == "" && change["renamed_file"] != true {
+			die("mr-comment: GitLab returned no diff for %s (too large, collapsed or binary), so line %d cannot be positioned", *file, *line)
+		}
+		form.Set("position[position_type]", "text")
+		form.Set("position[base_sha]", jsonStr(refs, "base_sha"))
+		form.Set("position[start_sha]", jsonStr(refs, "start_sha"))
+		form.Set("position[head_sha]", jsonStr(refs, "head_sha"))
+		form.Set("position[new_path]", jsonStr(change, "new_path"))
+		form.Set("position[old_path]", jsonStr(change, "old_path"))
+
+		kind, other := diffLineKind(jsonStr(change, "diff"), *line, *old)
+		switch {
+		case *old && kind == "removed":
+			form.Set("position[old_line]", strconv.Itoa(*line))
+		case *old:
+			form.Set("position[old_line]", strconv.Itoa(*line))
+			form.Set("position[new_line]", strconv.Itoa(other))
+		case kind == "added":
+			form.Set("position[new_line]", strconv.Itoa(*line))
+		default:
+			form.Set("position[new_line]", strconv.Itoa(*line))
+			form.Set("position[old_line]", strconv.Itoa(other))
+		}
+		anchor = fmt.Sprintf(" on %s:%d (%s line)", *file, *line, kind)
+	}
+
+	data, err := c.post(mrEndpoint+"/discussions", form)
+	if err != nil {
+		die("mr-comment: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("discussion %s started%s\n", jsonStr(m, "id"), anchor)
+}
+
+// ── Diff rendering ──────────────────────────────────────────
+
+// diffTruncated reports whether GitLab withheld a file's diff body
+// (too large, collapsed, or dropped by the changes overflow limit).
+func diffTruncated(d map[string]any) bool {
+	if jsonStr(d, "diff") != "" {
+		return false
+	}
+	return d["too_large"] == true || d["collapsed"] == true || d["overflow"] == true
+}
+
+// diffHeader builds the git-style header GitLab omits from its diff field.
+func diffHeader(d map[string]any) string {
+	oldPath, newPath := jsonStr(d, "old_path"), jsonStr(d, "new_path")
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\n", oldPath, newPath)
+	aMode, bMode := jsonStr(d, "a_mode"), jsonStr(d, "b_mode")
+	switch {
+	case d["new_file"] == true:
+		fmt.Fprintf(&b, "new file mode %s\n", strOr(bMode, "100644"))
+	case d["deleted_file"] == true:
+		fmt.Fprintf(&b, "deleted file mode %s\n", strOr(aMode, "100644"))
+	case aMode != "" && bMode != "" && aMode != bMode:
+		fmt.Fprintf(&b, "old mode %s\nnew mode %s\n", aMode, bMode)
+	}
+	if d["renamed_file"] == true {
+		fmt.Fprintf(&b, "rename from %s\nrename to %s\n", oldPath, newPath)
+	}
+	if jsonStr(d, "diff") == "" {
+		return b.String()
+	}
+	from, to := "a/"+oldPath, "b/"+newPath
+	if d["new_file"] == true {
+		from = "/dev/null"
+	}
+	if d["deleted_file"] == true {
+		to = "/dev/null"
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", from, to)
+	return b.String()
+}
+
+// splitRawDiff splits a full `git diff` text into per-file sections keyed
+// by new path (old path for deletions). Paths come from the ---/+++ lines
+// and fall back to "rename to" or the "diff --git" line, where a/ and b/
+// halves of equal length resolve paths that themselves contain " b/".
+// Sections whose path cannot be determined are dropped.
+func splitRawDiff(raw string) map[string]string {
+	sections := map[string]string{}
+	var cur strings.Builder
+	var header, minus, plus, renameTo string
+	inHunk := false
+	flush := func() {
+		if key := strOr(plus, strOr(renameTo, strOr(gitHeaderPath(header), minus))); key != "" && cur.Len() > 0 {
+			sections[key] = cur.String()
+		}
+		cur.Reset()
+		header, minus, plus, renameTo, inHunk = "", "", "", "", false
+	}
+	for _, l := range strings.SplitAfter(raw, "\n") {
+		text := strings.TrimRight(l, "\n")
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			flush()
+			header = strings.TrimPrefix(text, "diff --git ")
+		case strings.HasPrefix(text, "@@"):
+			inHunk = true
+		case inHunk:
+		case strings.HasPrefix(text, "--- "):
+			minus = gitDiffPath(strings.TrimPrefix(text, "--- "), "a/")
+		case strings.HasPrefix(text, "+++ "):
+			plus = gitDiffPath(strings.TrimPrefix(text, "+++ "), "b/")
+		case strings.HasPrefix(text, "rename to "):
+			renameTo = gitDiffPath(strings.TrimPrefix(text, "rename to "), "")
+		}
+		cur.WriteString(l)
+	}
+	flush()
+	return sections
+}
+
+// gitDiffPath unquotes a path from a diff header line and strips its a/
+// or b/ prefix; /dev/null yields "".
+func gitDiffPath(p, prefix string) string {
+	p = strings.TrimSuffix(p, "\t") // git appends a tab to paths with spaces
+	if strings.HasPrefix(p, `"`) {
+		if u, err := strconv.Unquote(p); err == nil {
+			p = u
+		}
+	}
+	if p == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(p, prefix)
+}
+
+// gitHeaderPath reads the path from the rest of a "diff --git" line when
+// both sides name the same file, which is all a header can tell reliably.
+func gitHeaderPath(rest string) string {
+	if strings.HasPrefix(rest, `"`) {
+		// Quoted paths: `"a/x y" "b/x y"`.
+		if end := strings.Index(rest[1:], `" "`); end >= 0 {
+			a, b := gitDiffPath(rest[:end+2], "a/"), gitDiffPath(rest[end+3:], "b/")
+			if a == b {
+				return b
+			}
+		}
+		return ""
+	}
+	if (len(rest)-5)%2 != 0 || len(rest) < 7 {
+		return ""
+	}
+	n := (len(rest) - 5) / 2
+	if p := rest[2 : 2+n]; rest == "a/"+p+" b/"+p {
+		return p
+	}
+	return ""
+}
+
+// countDiff returns the added and removed line counts of a hunk body.
+func countDiff(diff string) (adds, dels int) {
+	inHunk := false
+	for _, l := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			inHunk = true
+		case !inHunk:
+			// file headers ("--- a/x", "+++ b/x") precede the first hunk
+		case strings.HasPrefix(l, "+"):
+			adds++
+		case strings.HasPrefix(l, "-"):
+			dels++
+		}
+	}
+	return adds, dels
+}
+
+func diffStatus(d map[string]any) string {
+	switch {
+	case d["new_file"] == true:
+		return "added"
+	case d["deleted_file"] == true:
+		return "deleted"
+	case d["renamed_file"] == true:
+		return "renamed from " + jsonStr(d, "old_path")
+	}
+	return "modified"
+}
+
+// renderDiffs prints diffs as a file list (default), a git-style --stat
+// summary, or full unified diffs. raw holds per-file sections from the
+// raw diff endpoint and is used for entries GitLab truncated.
+func renderDiffs(w io.Writer, diffs []map[string]any, raw map[string]string, mode string) {
+	body := func(d map[string]any) (text string, full, ok bool) {
+		if !diffTruncated(d) {
+			return jsonStr(d, "diff"), false, true
+		}
+		if sec, found := raw[jsonStr(d, "new_path")]; found {
+			return sec, true, true
+		}
+		return "", false, false
+	}
+	switch mode {
+	case "stat":
+		width := 0
+		type row struct {
+			path       string
+			adds, dels int
+			note       string
+		}
+		var rows []row
+		maxChange := 0
+		totalAdds, totalDels := 0, 0
+		for _, d := range diffs {
+			r := row{path: jsonStr(d, "new_path")}
+			if d["renamed_file"] == true {
+				r.path = jsonStr(d, "old_path") + " => " + r.path
+			}
+			text, _, ok := body(d)
+			if ok {
+				r.adds, r.dels = countDiff(text)
+			} else {
+				r.note = "(too large)"
+			}
+			if text == "" && ok && d["new_file"] != true && d["deleted_file"] != true && d["renamed_file"] != true {
+				r.note = "Bin"
+				if a, b := jsonStr(d, "a_mode"), jsonStr(d, "b_mode"); a != b {
+					r.note = "mode " + a + " => " + b
+				}
+			}
+			totalAdds += r.adds
+			totalDels += r.dels
+			if n := len(r.path); n > width {
+				width = n
+			}
+			if n := r.adds + r.dels; n > maxChange {
+				maxChange = n
+			}
+			rows = append(rows, r)
+		}
+		if width > 60 {
+			width = 60
+		}
+		for _, r := range rows {
+			path := r.path
+			if len(path) > width {
+				path = "..." + path[len(path)-width+3:]
+			}
+			if r.note != "" {
+				fmt.Fprintf(w, " %-*s | %s\n", width, path, r.note)
+				continue
+			}
+			plus, minus := r.adds, r.dels
+			if maxChange > 40 {
+				plus = (r.adds*40 + maxChange - 1) / maxChange
+				minus = (r.dels*40 + maxChange - 1) / maxChange
+			}
+			line := fmt.Sprintf(" %-*s | %4d %s%s", width, path, r.adds+r.dels,
+				strings.Repeat("+", plus), strings.Repeat("-", minus))
+			fmt.Fprintln(w, strings.TrimRight(line, " "))
+		}
+		fmt.Fprintf(w, " %d file(s) changed, %d insertion(s)(+), %d deletion(s)(-)\n",
+			len(rows), totalAdds, totalDels)
+	case "diff":
+		for _, d := range diffs {
+			text, full, ok := body(d)
+			switch {
+			case !ok:
+				fmt.Fprint(w, diffHeader(d))
+				fmt.Fprintf(w, "# diff for %s is too large and was not returned by the API\n", jsonStr(d, "new_path"))
+			case full:
+				fmt.Fprint(w, text)
+			default:
+				fmt.Fprint(w, diffHeader(d))
+				if text == "" && d["new_file"] != true && d["deleted_file"] != true && d["renamed_file"] != true &&
+					jsonStr(d, "a_mode") == jsonStr(d, "b_mode") {
+					fmt.Fprintln(w, "Binary files differ")
+				}
+				fmt.Fprint(w, text)
+			}
+			if text != "" && !strings.HasSuffix(text, "\n") {
+				fmt.Fprintln(w)
+			}
+		}
+	default:
+		for _, d := range diffs {
+			note := ""
+			if diffTruncated(d) {
+				note = " [diff truncated]"
+			}
+			fmt.Fprintf(w, "%s (%s)%s\n\n", jsonStr(d, "new_path"), diffStatus(d), note)
+		}
+	}
+}
+
+func diffMode(stat, diff bool) string {
+	switch {
+	case stat && diff:
+		die("--stat and --diff are mutually exclusive")
+	case stat:
+		return "stat"
+	case diff:
+		return "diff"
+	}
+	return "files"
+}
+
+// cmdCompare shows commits and changes between two refs.
+//
+//	<host> compare <project> <from> <to> [--stat | --diff] [--straight]
+//
+// By default the comparison is from the merge base (from...to); --straight
+// compares the two trees directly (from..to).
+func cmdCompare(c *apiClient, args []string) {
+	if len(args) < 3 {
+		die("Usage: compare <project> <from> <to> [--stat | --diff] [--straight]")
+	}
+	encoded := url.PathEscape(args[0])
+	from, to := args[1], args[2]
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	stat := fs.Bool("stat", false, "print a diffstat summary")
+	diff := fs.Bool("diff", false, "print full unified diffs")
+	straight := fs.Bool("straight", false, "compare trees directly instead of from the merge base")
+	_ = fs.Parse(args[3:])
+	mode := diffMode(*stat, *diff)
+
+	data, err := c.get("/projects/"+encoded+"/repository/compare", url.Values{
+		"from": {from}, "to": {to}, "straight": {strconv.FormatBool(*straight)},
+	})
+	if err != nil {
+		die("%s", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	if m["compare_same_ref"] == true {
+		fmt.Printf("%s and %s are the same ref\n", from, to)
+		return
+	}
+	if m["compare_timeout"] == true {
+		fmt.Fprintln(os.Stderr, "warning: comparison timed out on the server; results are partial")
+	}
+	commits := jsonArr(m, "commits")
+	sep := "..."
+	if *straight {
+		sep = ".."
+	}
+	fmt.Printf("%d commit(s) %s%s%s:\n", len(commits), from, sep, to)
+	for _, cm := range commits {
+		cmm := asMap(cm)
+		fmt.Printf("  %s %s (%s)\n", jsonStr(cmm, "short_id"), jsonStr(cmm, "title"), jsonStr(cmm, "author_name"))
+	}
+	fmt.Println()
+	var diffs []map[string]any
+	for _, d := range jsonArr(m, "diffs") {
+		if dm := asMap(d); dm != nil {
+			diffs = append(diffs, dm)
+		}
+	}
+	renderDiffs(os.Stdout, diffs, nil, mode)
+	if web := jsonStr(m, "web_url"); web != "" {
+		fmt.Printf("\n%s\n", web)
+	}
+}
+
+// ── CI job logs ─────────────────────────────────────────────
+
+var (
+	ansiRe          = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+	sectionMarkerRe = regexp.MustCompile(`section_(start|end):\d+:([A-Za-z0-9_.\-]+)(\[[^\]]*\])?\r?`)
+	errorLineRe     = regexp.MustCompile(`(?i)(\berror\b|\bfailed\b|\bfailure\b|\bfatal\b|\bpanic:|\bexception\b|traceback|\bassert(ion)?\b|exit (code|status) [1-9]|command not found|no such file|permission denied|--- FAIL|^FAIL\b|✗|✘)`)
+)
+
+// traceLine is one cleaned log line and the CI section it belongs to.
+type traceLine struct {
+	Text    string
+	Section string
+}
+
+// scriptSections hold the job's own script ("build_script" on older
+// runners); the error region comes from there when present.
+var scriptSections = map[string]bool{"step_script": true, "build_script": true}
+
+// housekeepingSections are runner-generated sections that rarely explain
+// a failure. Without a script section, the error region is taken from the
+// last section not listed.
+var housekeepingSections = map[string]bool{
+	"resolve_secrets": true, "prepare_executor": true, "prepare_script": true,
+	"get_sources": true, "restore_cache": true, "download_artifacts": true,
+	"after_script": true, "archive_cache": true, "archive_cache_on_failure": true,
+	"upload_artifacts_on_success": true, "upload_artifacts_on_failure": true,
+	"cleanup_file_variables": true,
+}
+
+// parseTrace strips ANSI colours and GitLab section markers from a raw
+// job trace, collapses carriage-return progress updates to their final
+// state, and tags each line with its section.
+func parseTrace(raw string) []traceLine {
+	var out []traceLine
+	var stack []string
+	for _, l := range strings.Split(raw, "\n") {
+		markers := sectionMarkerRe.FindAllStringSubmatch(l, -1)
+		for _, m := range markers {
+			if m[1] == "start" {
+				stack = append(stack, m[2])
+			} else if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		l = sectionMarkerRe.ReplaceAllString(l, "")
+		l = ansiRe.ReplaceAllString(l, "")
+		l = strings.TrimRight(l, "\r")
+		if i := strings.LastIndex(l, "\r"); i >= 0 {
+			l = l[i+1:]
+		}
+		if len(markers) > 0 && strings.TrimSpace(l) == "" {
+			continue
+		}
+		section := ""
+		if len(stack) > 0 {
+			section = stack[len(stack)-1]
+		}
+		out = append(out, traceLine{Text: l, Section: section})
+	}
+	for len(out) > 0 && out[len(out)-1].Text == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// errorRegion picks the lines that most likely explain a failure: lines
+// matching errorLineRe (with context) inside the job's script section (or
+// else the last non-housekeeping section), plus that section's tail. Gaps
+// are marked with "...".
+func errorRegion(lines []traceLine, context, tail, max int) []string {
+	start, end := 0, len(lines)
+	last := ""
+	for _, pick := range []func(string) bool{
+		func(s string) bool { return scriptSections[s] },
+		func(s string) bool { return s != "" && !housekeepingSections[s] },
+	} {
+		for i := len(lines) - 1; i >= 0 && last == ""; i-- {
+			if s := lines[i].Section; pick(s) {
+				last = s
+				end = i + 1
+			}
+		}
+	}
+	if last != "" {
+		start = end - 1
+		for start > 0 && lines[start-1].Section == last {
+			start--
+		}
+	}
+	keep := map[int]bool{}
+	for i := start; i < end; i++ {
+		if errorLineRe.MatchString(lines[i].Text) {
+			for j := i - context; j <= i+context; j++ {
+				if j >= start && j < end {
+					keep[j] = true
+				}
+			}
+		}
+	}
+	for j := end - tail; j < end; j++ {
+		if j >= start {
+			keep[j] = true
+		}
+	}
+	var out []string
+	prev := -2
+	for i := start; i < end; i++ {
+		if !keep[i] {
+			continue
+		}
+		if prev >= 0 && i != prev+1 {
+			out = append(out, "...")
+		}
+		out = append(out, lines[i].Text)
+		prev = i
+	}
+	if len(out) > max {
+		out = append([]string{"..."}, out[len(out)-max:]...)
+	}
+	return out
+}
+
+func jobTrace(c *apiClient, encoded, jobID string) (string, error) {
+	data, err := c.getRaw("/projects/"+encoded+"/jobs/"+jobID+"/trace", nil)
+	return string(data), err
+}
+
+// cmdJobLog prints a job's cleaned log. --follow polls a running job and
+// prints new output until it finishes, exiting non-zero if it failed.
+//
+//	<host> job-log <project> <job-id> [--tail N] [--follow] [--interval 3s] [--raw]
+func cmdJobLog(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: job-log <project> <job-id> [--tail N] [--follow] [--raw]")
+	}
+	encoded := url.PathEscape(args[0])
+	jobID := args[1]
+	fs := flag.NewFlagSet("job-log", flag.ExitOnError)
+	tail := fs.Int("tail", 0, "only print the last N lines")
+	follow := fs.Bool("follow", false, "keep printing output until the job finishes")
+	interval := fs.Duration("interval", 3*time.Second, "poll interval for --follow")
+	raw := fs.Bool("raw", false, "print the trace without cleaning")
+	_ = fs.Parse(args[2:])
+
+	trace, err := jobTrace(c, encoded, jobID)
+	if err != nil {
+		die("%s", err)
+	}
+	render := func(text string) []string {
+		if *raw {
+			return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+		}
+		var out []string
+		for _, l := range parseTrace(text) {
+			out = append(out, l.Text)
+		}
+		return out
+	}
+	lines := render(trace)
+	if *tail > 0 && len(lines) > *tail {
+		lines = lines[len(lines)-*tail:]
+	}
+	if !*follow {
+		fmt.Println(strings.Join(lines, "\n"))
+		return
+	}
+
+	// Only print complete lines; hold back a trailing partial line until
+	// the next poll so cleaning never splits an escape sequence.
+	printed := 0
+	emit := func(text string, final bool) {
+		if !final {
+			if i := strings.LastIndex(text, "\n"); i >= 0 {
+				text = text[:i+1]
+			} else {
+				text = ""
+			}
+		}
+		if len(text) <= printed {
+			return
+		}
+		chunk := text[printed:]
+		printed = len(text)
+		for _, l := range render(chunk) {
+			fmt.Println(l)
+		}
+	}
+	if *tail > 0 {
+		for _, l := range lines {
+			fmt.Println(l)
+		}
+		if i := strings.LastIndex(trace, "\n"); i >= 0 {
+			printed = i + 1
+		}
+	}
+	for {
+		emit(trace, false)
+		data, err := c.get("/projects/"+encoded+"/jobs/"+jobID, nil)
+		if err != nil {
+			die("%s", err)
+		}
+		var job map[string]any
+		json.Unmarshal(data, &job)
+		status := jsonStr(job, "status")
+		switch status {
+		case "created", "pending", "running", "waiting_for_resource", "preparing", "scheduled":
+			time.Sleep(*interval)
+			if trace, err = jobTrace(c, encoded, jobID); err != nil {
+				die("%s", err)
+			}
+			continue
+		}
+		if trace, err = jobTrace(c, encoded, jobID); err == nil {
+			emit(trace, true)
+		}
+		fmt.Fprintf(os.Stderr, "job %s finished: %s\n", jobID, status)
+		if status != "success" && status != "manual" {
+			os.Exit(1)
+		}
+		return
+	}
+}
+
+// failedJobs lists failed jobs of a pipeline and, through failed bridge
+// jobs, of its downstream pipelines. Each job map gains a "_project" key
+// with the encoded project it belongs to.
+func failedJobs(c *apiClient, encoded, pipelineID string, depth int) ([]map[string]any, error) {
+	items, err := c.getAll("/projects/"+encoded+"/pipelines/"+pipelineID+"/jobs", url.Values{"scope[]": {"failed"}})
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]any
+	for _, it := range items {
+		if jm := asMap(it); jm != nil {
+			jm["_project"] = encoded
+			out = append(out, jm)
+		}
+	}
+	if depth >= 3 {
+		return out, nil
+	}
+	bridges, err := c.getAll("/projects/"+encoded+"/pipelines/"+pipelineID+"/bridges", url.Values{"scope[]": {"failed"}})
+	if err != nil {
+		return out, nil
+	}
+	for _, b := range bridges {
+		ds := jsonMap(asMap(b), "downstream_pipeline")
+		if ds == nil {
+			continue
+		}
+		child, err := failedJobs(c, url.PathEscape(jsonStr(ds, "project_id")), jsonStr(ds, "id"), depth+1)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: downstream pipeline %s: %v\n", jsonStr(ds, "id"), err)
+			continue
+		}
+		out = append(out, child...)
+	}
+	return out, nil
+}
+
+var jobFailedRe = regexp.MustCompile(`^ERROR: Job failed.*`)
+
+// cmdPipelineFailures prints, for every failed job in a pipeline (and its
+// downstream pipelines), the failure reason and the log region that most
+// likely explains it.
+//
+//	<host> pipeline-failures <project> <pipeline-id> [--context 3] [--tail 15] [--max-lines 80]
+func cmdPipelineFailures(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: pipeline-failures <project> <pipeline-id> [--context N] [--tail N] [--max-lines N]")
+	}
+	encoded := url.PathEscape(args[0])
+	pipelineID := args[1]
+	fs := flag.NewFlagSet("pipeline-failures", flag.ExitOnError)
+	context := fs.Int("context", 3, "lines of context around each error line")
+	tail := fs.Int("tail", 15, "always include this many trailing lines of the failing section")
+	maxLines := fs.Int("max-lines", 80, "cap on lines printed per job")
+	_ = fs.Parse(args[2:])
+
+	jobs, err := failedJobs(c, encoded, pipelineID, 0)
+	if err != nil {
+		die("%s", err)
+	}
+	if len(jobs) == 0 {
+		fmt.Printf("Pipeline %s has no failed jobs\n", pipelineID)
+		return
+	}
+	fmt.Printf("Pipeline %s: %d failed job(s)\n\n", pipelineID, len(jobs))
+	for _, jm := range jobs {
+		allow := ""
+		if jm["allow_failure"] == true {
+			allow = " (allowed to fail)"
+		}
+		fmt.Printf("✗ %s [%s] job %s%s\n", jsonStr(jm, "name"), jsonStr(jm, "stage"), jsonStr(jm, "id"), allow)
+		fmt.Printf("  reason: %s  duration: %ss\n", strOr(jsonStr(jm, "failure_reason"), "unknown"),
+			strOr(jsonStr(jm, "duration"), "0"))
+		fmt.Printf("  %s\n", jsonStr(jm, "web_url"))
+		trace, err := jobTrace(c, jsonStr(jm, "_project"), jsonStr(jm, "id"))
+		if err != nil {
+			fmt.Printf("  (log unavailable: %v)\n\n", err)
+			continue
+		}
+		lines := parseTrace(trace)
+		for _, l := range lines {
+			if jobFailedRe.MatchString(l.Text) {
+				fmt.Printf("  %s\n", l.Text)
+			}
+		}
+		fmt.Println("  ----")
+		for _, l := range errorRegion(lines, *context, *tail, *maxLines) {
+			fmt.Printf("  | %s\n", l)
+		}
+		fmt.Println()
+	}
+}
+
+// ── Pipeline control ────────────────────────────────────────
+
+// stringList is a repeatable string flag (-v A=1 -v B=2).
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+// parseVars turns KEY=VAL pairs into the variable objects the pipeline
+// and job-play endpoints accept.
+func parseVars(pairs []string) ([]map[string]string, error) {
+	var out []map[string]string
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("variable %q must be KEY=VALUE", p)
+		}
+		out = append(out, map[string]string{"key": k, "value": v})
+	}
+	return out, nil
+}
+
+func pipelineTerminal(status string) bool {
+	switch status {
+	case "success", "failed", "canceled", "skipped", "manual":
+		return true
+	}
+	return false
+}
+
+// waitPipeline polls a pipeline until it reaches a terminal status,
+// printing job status transitions as they happen. The poll interval backs
+// off while nothing changes and resets when something does. Returns the
+// final pipeline status, or "timeout".
+func waitPipeline(c *apiClient, encoded, pipelineID string, timeout, interval, maxInterval time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	seen := map[string]string{}
+	lastStatus := ""
+	wait := interval
+	retry := interval
+	for {
+		data, err := c.get("/projects/"+encoded+"/pipelines/"+pipelineID, nil)
+		var jobs []any
+		if err == nil {
+			jobs, err = c.getAll("/projects/"+encoded+"/pipelines/"+pipelineID+"/jobs", nil)
+		}
+		if err != nil {
+			if !transientErr(err) {
+				toolFail("%s", err)
+			}
+			if time.Now().Add(retry).After(deadline) {
+				toolFail("%s (gave up retrying at timeout)", err)
+			}
+			fmt.Fprintf(os.Stderr, "%s %v; retrying in %s\n", time.Now().Format("15:04:05"), err, retry)
+			time.Sleep(retry)
+			if retry *= 2; retry > maxInterval {
+				retry = maxInterval
+			}
+			continue
+		}
+		retry = interval
+		var p map[string]any
+		json.Unmarshal(data, &p)
+		status := jsonStr(p, "status")
+		changed := false
+		if status != lastStatus {
+			fmt.Printf("%s pipeline %s: %s\n", time.Now().Format("15:04:05"), pipelineID, status)
+			lastStatus = status
+			changed = true
+		}
+
+		for _, j := range jobs {
+			jm := asMap(j)
+			id, js := jsonStr(jm, "id"), jsonStr(jm, "status")
+			if prev, ok := seen[id]; !ok || prev != js {
+				from := prev
+				if !ok {
+					from = "new"
+				}
+				fmt.Printf("%s   %-30s %s -> %s\n", time.Now().Format("15:04:05"),
+					jsonStr(jm, "name")+" ["+jsonStr(jm, "stage")+"]", from, js)
+				seen[id] = js
+				changed = true
+			}
+		}
+
+		if pipelineTerminal(status) {
+			return status
+		}
+		if time.Now().After(deadline) {
+			return "timeout"
+		}
+		if changed {
+			wait = interval
+		} else if wait = wait * 3 / 2; wait > maxInterval {
+			wait = maxInterval
+		}
+		if remaining := time.Until(deadline); wait > remaining {
+			wait = remaining
+		}
+		time.Sleep(wait)
+	}
+}
+
+// exitToolError is the exit code of pipeline-wait and pipeline-run --wait
+// when the tool itself fails (bad arguments or flags, unknown host, bad
+// project, auth, API unreachable), so CI callers can tell it apart from a
+// failed pipeline or a timeout.
+const exitToolError = 4
+
+func toolFail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "ERROR: "+format+"\n", args...)
+	os.Exit(exitToolError)
+}
+
+// parseToolFlags parses a flag.ContinueOnError set and exits with
+// exitToolError on a bad flag, so a typo is never read as a timeout (2).
+func parseToolFlags(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(exitToolError)
+	}
+}
+
+// transientErr reports whether a polling error is worth retrying: network
+// failures, rate limiting and server errors.
+func transientErr(err error) bool {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return ae.StatusCode == http.StatusTooManyRequests || ae.StatusCode >= 500
+	}
+	return true
+}
+
+// exitForPipeline maps a final pipeline status to the documented exit
+// codes: 0 success, 1 failed/canceled/skipped, 2 timeout, 3 waiting on a
+// manual job. Tool errors exit with exitToolError.
+func exitForPipeline(project, pipelineID, status string) {
+	switch status {
+	case "success":
+		return
+	case "timeout":
+		fmt.Fprintf(os.Stderr, "pipeline %s still running at timeout\n", pipelineID)
+		os.Exit(2)
+	case "manual":
+		fmt.Fprintf(os.Stderr, "pipeline %s is blocked on a manual job (use job-play)\n", pipelineID)
+		os.Exit(3)
+	default:
+		fmt.Fprintf(os.Stderr, "pipeline %s %s — see: pipeline-failures %s %s\n", pipelineID, status, project, pipelineID)
+		os.Exit(1)
+	}
+}
+
+func addWaitFlags(fs *flag.FlagSet) (timeout, interval, maxInterval *time.Duration) {
+	timeout = fs.Duration("timeout", 30*time.Minute, "give up after this long")
+	interval = fs.Duration("interval", 5*time.Second, "initial poll interval")
+	maxInterval = fs.Duration("max-interval", time.Minute, "poll interval ceiling while nothing changes")
+	return
+}
+
+// cmdPipelineRun starts a pipeline on a ref, optionally waiting for it.
+// Usage and flag errors exit with exitToolError, as --wait documents.
+//
+//	<host> pipeline-run <project> <ref> [-v KEY=VAL ...] [--wait [--timeout 30m]]
+func cmdPipelineRun(c *apiClient, args []string) {
+	if len(args) < 2 {
+		toolFail("Usage: pipeline-run <project> <ref> [-v KEY=VAL ...] [--wait]")
+	}
+	encoded := url.PathEscape(args[0])
+	ref := args[1]
+	fs := flag.NewFlagSet("pipeline-run", flag.ContinueOnError)
+	var vars stringList
+	fs.Var(&vars, "v", "pipeline variable KEY=VALUE (repeatable)")
+	waitFlag := fs.Bool("wait", false, "wait for the pipeline to finish (same exit codes as pipeline-wait)")
+	timeout, interval, maxInterval := addWaitFlags(fs)
+	parseToolFlags(fs, args[2:])
+
+	payload := map[string]any{"ref": ref}
+	if len(vars) > 0 {
+		pv, err := parseVars(vars)
+		if err != nil {
+			toolFail("pipeline-run: %v", err)
+		}
+		payload["variables"] = pv
+	}
+	data, err := c.sendJSON("POST", "/projects/"+encoded+"/pipeline", payload)
+	if err != nil {
+		if *waitFlag {
+			toolFail("pipeline-run: %v", err)
+		}
+		die("pipeline-run: %v", err)
+	}
+	var p map[string]any
+	json.Unmarshal(data, &p)
+	id := jsonStr(p, "id")
+	fmt.Printf("pipeline %s created on %s (%s)\n  %s\n", id, ref, jsonStr(p, "status"), jsonStr(p, "web_url"))
+	if *waitFlag {
+		exitForPipeline(args[0], id, waitPipeline(c, encoded, id, *timeout, *interval, *maxInterval))
+	}
+}
+
+// cmdPipelineWait blocks until a pipeline finishes, streaming job status
+// changes. Transient API errors are retried with backoff. Exit codes:
+// 0 success, 1 failed/canceled/skipped, 2 timeout, 3 blocked on a manual
+// job, 4 tool, usage or API error.
+//
+//	<host> pipeline-wait <project> <id> [--timeout 30m] [--interval 5s] [--max-interval 1m]
+func cmdPipelineWait(c *apiClient, args []string) {
+	if len(args) < 2 {
+		toolFail("Usage: pipeline-wait <project> <id> [--timeout 30m]")
+	}
+	encoded := url.PathEscape(args[0])
+	pipelineID := args[1]
+	fs := flag.NewFlagSet("pipeline-wait", flag.ContinueOnError)
+	timeout, interval, maxInterval := addWaitFlags(fs)
+	parseToolFlags(fs, args[2:])
+	exitForPipeline(args[0], pipelineID, waitPipeline(c, encoded, pipelineID, *timeout, *interval, *maxInterval))
+}
+
+// cmdPipelineAction runs retry or cancel on a whole pipeline.
+//
+//	<host> pipeline-retry <project> <id>
+//	<host> pipeline-cancel <project> <id>
+func cmdPipelineAction(c *apiClient, args []string, action string) {
+	if len(args) < 2 {
+		die("Usage: pipeline-%s <project> <id>", action)
+	}
+	encoded := url.PathEscape(args[0])
+	data, err := c.post("/projects/"+encoded+"/pipelines/"+args[1]+"/"+action, nil)
+	if err != nil {
+		die("pipeline-%s: %v", action, err)
+	}
+	var p map[string]any
+	json.Unmarshal(data, &p)
+	fmt.Printf("pipeline %s: %s\n  %s\n", jsonStr(p, "id"), jsonStr(p, "status"), jsonStr(p, "web_url"))
+}
+
+// cmdJobRetry retries a single job; GitLab creates a new job ID.
+//
+//	<host> job-retry <project> <job-id>
+func cmdJobRetry(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: job-retry <project> <job-id>")
+	}
+	encoded := url.PathEscape(args[0])
+	data, err := c.post("/projects/"+encoded+"/jobs/"+args[1]+"/retry", nil)
+	if err != nil {
+		die("job-retry: %v", err)
+	}
+	var j map[string]any
+	json.Unmarshal(data, &j)
+	fmt.Printf("job %s retried as job %s (%s)\n  %s\n", args[1], jsonStr(j, "id"), jsonStr(j, "status"), jsonStr(j, "web_url"))
+}
+
+// cmdJobPlay triggers a manual job, optionally with job variables.
+//
+//	<host> job-play <project> <job-id> [-v KEY=VAL ...]
+func cmdJobPlay(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: job-play <project> <job-id> [-v KEY=VAL ...]")
+	}
+	encoded := url.PathEscape(args[0])
+	fs := flag.NewFlagSet("job-play", flag.ExitOnError)
+	var vars stringList
+	fs.Var(&vars, "v", "job variable KEY=VALUE (repeatable)")
+	_ = fs.Parse(args[2:])
+
+	payload := map[string]any{}
+	if len(vars) > 0 {
+		jv, err := parseVars(vars)
+		if err != nil {
+			die("job-play: %v", err)
+		}
+		payload["job_variables_attributes"] = jv
+	}
+	data, err := c.sendJSON("POST", "/projects/"+encoded+"/jobs/"+args[1]+"/play", payload)
+	if err != nil {
+		var ae *apiError
+		if errors.As(err, &ae) && ae.StatusCode == 400 {
+			die("job-play: job %s is not a playable manual job\n%s", args[1], err)
+		}
+		die("job-play: %v", err)
+	}
+	var j map[string]any
+	json.Unmarshal(data, &j)
+	fmt.Printf("job %s %s (%s)\n  %s\n", jsonStr(j, "id"), jsonStr(j, "name"), jsonStr(j, "status"), jsonStr(j, "web_url"))
+}
+
+// ── Artifacts and test reports ──────────────────────────────
+
+// download streams a GET response body to w without buffering it.
+func (c *apiClient) download(endpoint string, params url.Values, w io.Writer) (int64, error) {
+	u := c.baseURL + "/api/v4" + endpoint
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.password)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return 0, &apiError{StatusCode: resp.StatusCode, Body: body}
+	}
+	return io.Copy(w, resp.Body)
+}
+
+// extractZip unpacks archive into dir, refusing entries that would land
+// outside dir (absolute paths, "..", symlinks).
+func extractZip(archive, dir string) (int, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+	// Validate every entry before writing anything so a hostile archive
+	// leaves nothing behind.
+	for _, f := range zr.File {
+		name := filepath.FromSlash(f.Name)
+		target := filepath.Join(root, name)
+		if filepath.IsAbs(name) || (target != root && !strings.HasPrefix(target, root+string(os.PathSeparator))) {
+			return 0, fmt.Errorf("refusing unsafe path %q in archive", f.Name)
+		}
+	}
+	n := 0
+	for _, f := range zr.File {
+		target := filepath.Join(root, filepath.FromSlash(f.Name))
+		mode := f.Mode()
+		switch {
+		case mode&os.ModeSymlink != 0:
+			fmt.Fprintf(os.Stderr, "warning: skipping symlink %s\n", f.Name)
+			continue
+		case f.FileInfo().IsDir():
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return n, err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return n, err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return n, err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+		if err != nil {
+			rc.Close()
+			return n, err
+		}
+		_, err = io.Copy(out, rc)
+		rc.Close()
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// cmdArtifacts downloads a job's artifacts archive, extracts it, or
+// fetches a single file from it.
+//
+//	<host> artifacts <project> <job-id> [--out file.zip]
+//	<host> artifacts <project> <job-id> --extract dir
+//	<host> artifacts <project> <job-id> --path reports/junit.xml [--out file]
+//
+// With --path and no --out the file is written to stdout.
+func cmdArtifacts(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: artifacts <project> <job-id> [--path file] [--extract dir] [--out file]")
+	}
+	encoded := url.PathEscape(args[0])
+	jobID := args[1]
+	fs := flag.NewFlagSet("artifacts", flag.ExitOnError)
+	path := fs.String("path", "", "fetch a single file from the archive")
+	extract := fs.String("extract", "", "extract the archive into this directory")
+	outFile := fs.String("out", "", "output file (default artifacts-<job>.zip, or stdout with --path)")
+	_ = fs.Parse(args[2:])
+	if *path != "" && *extract != "" {
+		die("artifacts: --path and --extract are mutually exclusive")
+	}
+
+	endpoint := "/projects/" + encoded + "/jobs/" + jobID + "/artifacts"
+	if *path != "" {
+		segments := strings.Split(strings.TrimPrefix(*path, "/"), "/")
+		for i, seg := range segments {
+			segments[i] = url.PathEscape(seg)
+		}
+		endpoint += "/" + strings.Join(segments, "/")
+		var w io.Writer = os.Stdout
+		var f *os.File
+		if *outFile != "" {
+			var err error
+			if f, err = os.Create(*outFile); err != nil {
+				die("%s", err)
+			}
+			w = f
+		}
+		n, err := c.download(endpoint, nil, w)
+		if f != nil {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(*outFile)
+			}
+		}
+		if err != nil {
+			var ae *apiError
+			if errors.As(err, &ae) && ae.StatusCode == 404 {
+				die("artifacts: %s not found in job %s artifacts (or they expired)", *path, jobID)
+			}
+			die("artifacts: %v", err)
+		}
+		if *outFile != "" {
+			fmt.Printf("%s: %d bytes\n", *outFile, n)
+		}
+		return
+	}
+
+	target := strOr(*outFile, "artifacts-"+jobID+".zip")
+	if *extract != "" && *outFile == "" {
+		tmp, err := os.CreateTemp("", "artifacts-*.zip")
+		if err != nil {
+			die("%s", err)
+		}
+		tmp.Close()
+		target = tmp.Name()
+		defer os.Remove(target)
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		die("%s", err)
+	}
+	n, err := c.download(endpoint, nil, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(target)
+		var ae *apiError
+		if errors.As(err, &ae) && ae.StatusCode == 404 {
+			die("artifacts: job %s has no artifacts (or they expired)", jobID)
+		}
+		die("artifacts: %v", err)
+	}
+	if *extract == "" {
+		fmt.Printf("%s: %d bytes\n", target, n)
+		return
+	}
+	count, err := extractZip(target, *extract)
+	if err != nil {
+		die("extract: %v", err)
+	}
+	fmt.Printf("extracted %d file(s) (%d bytes archive) into %s\n", count, n, *extract)
+}
+
+func firstLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = append(lines[:n], fmt.Sprintf("... (%d more lines)", len(lines)-n))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cmdTestReport summarizes a pipeline's JUnit test report: per-suite
+// counts, then every failed or errored test with its duration and the
+// start of its output.
+//
+//	<host> test-report <project> <pipeline-id> [--slowest N] [--lines N]
+func cmdTestReport(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: test-report <project> <pipeline-id> [--slowest N] [--lines N]")
+	}
+	encoded := url.PathEscape(args[0])
+	pipelineID := args[1]
+	fs := flag.NewFlagSet("test-report", flag.ExitOnError)
+	slowest := fs.Int("slowest", 0, "also list the N slowest tests")
+	maxLines := fs.Int("lines", 8, "lines of failure output to show per test")
+	_ = fs.Parse(args[2:])
+
+	data, err := c.get("/projects/"+encoded+"/pipelines/"+pipelineID+"/test_report", nil)
+	if err != nil {
+		die("%s", err)
+	}
+	var r map[string]any
+	json.Unmarshal(data, &r)
+	if jsonStr(r, "total_count") == "0" || jsonStr(r, "total_count") == "" {
+		fmt.Printf("Pipeline %s has no JUnit test report (jobs need artifacts:reports:junit)\n", pipelineID)
+		return
+	}
+	fmt.Printf("Pipeline %s tests: %s total, %s passed, %s failed, %s errors, %s skipped (%.1fs)\n\n",
+		pipelineID, jsonStr(r, "total_count"), jsonStr(r, "success_count"), jsonStr(r, "failed_count"),
+		jsonStr(r, "error_count"), jsonStr(r, "skipped_count"), jsonNum(r, "total_time"))
+
+	type testCase struct {
+		suite, name, class, status, output, file string
+		secs                                     float64
+	}
+	var failed, all []testCase
+	for _, s := range jsonArr(r, "test_suites") {
+		sm := asMap(s)
+		fmt.Printf("  %-40s %4s total %4s failed %4s errors %4s skipped  %.1fs\n",
+			jsonStr(sm, "name"), jsonStr(sm, "total_count"), jsonStr(sm, "failed_count"),
+			jsonStr(sm, "error_count"), jsonStr(sm, "skipped_count"), jsonNum(sm, "total_time"))
+		if msg := jsonStr(sm, "suite_error"); msg != "" {
+			fmt.Printf("    suite error: %s\n", msg)
+		}
+		for _, tc := range jsonArr(sm, "test_cases") {
+			tm := asMap(tc)
+			t := testCase{
+				suite: jsonStr(sm, "name"), name: jsonStr(tm, "name"), class: jsonStr(tm, "classname"),
+				status: jsonStr(tm, "status"), file: jsonStr(tm, "file"), secs: jsonNum(tm, "execution_time"),
+				output: strOr(jsonStr(tm, "stack_trace"), jsonStr(tm, "system_output")),
+			}
+			all = append(all, t)
+			if t.status == "failed" || t.status == "error" {
+				failed = append(failed, t)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Printf("\nFailed tests (%d):\n", len(failed))
+		for _, t := range failed {
+			loc := t.class
+			if t.file != "" {
+				loc = t.file
+			}
+			fmt.Printf("\n  ✗ [%s] %s  (%s, %.2fs, suite %s)\n", t.status, t.name, loc, t.secs, t.suite)
+			if t.output != "" {
+				fmt.Println(indentLines(firstLines(t.output, *maxLines), "      "))
+			}
+		}
+	}
+	if *slowest > 0 {
+		sort.Slice(all, func(i, j int) bool { return all[i].secs > all[j].secs })
+		if len(all) > *slowest {
+			all = all[:*slowest]
+		}
+		fmt.Printf("\nSlowest %d tests:\n", len(all))
+		for _, t := range all {
+			fmt.Printf("  %8.2fs  %s (%s)\n", t.secs, t.name, t.suite)
+		}
+	}
+}
+
+// ── CI lint ─────────────────────────────────────────────────
+
+// cmdCILint validates CI configuration with the project's lint endpoint,
+// which expands includes in the project's context. With --file the local
+// file is posted; otherwise the repository's config at --ref is linted.
+// --dry-run simulates pipeline creation on --ref and lists the jobs that
+// would run. Exits non-zero when the config is invalid.
+//
+//	<host> ci-lint <project> [--file .gitlab-ci.yml] [--ref branch] [--dry-run] [--no-yaml]
+func cmdCILint(c *apiClient, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		die("Usage: ci-lint <project> [--file .gitlab-ci.yml] [--ref branch] [--dry-run] [--no-yaml]")
+	}
+	encoded := url.PathEscape(args[0])
+	fs := flag.NewFlagSet("ci-lint", flag.ExitOnError)
+	file := fs.String("file", "", "local CI file to lint (default: the repository's config)")
+	ref := fs.String("ref", "", "ref for includes / dry run (default: project default branch)")
+	dryRun := fs.Bool("dry-run", false, "simulate pipeline creation and list the jobs that would run")
+	noYAML := fs.Bool("no-yaml", false, "do not print the merged YAML")
+	_ = fs.Parse(args[1:])
+
+	endpoint := "/projects/" + encoded + "/ci/lint"
+	var data json.RawMessage
+	var err error
+	if *file != "" {
+		content, rerr := os.ReadFile(*file)
+		if rerr != nil {
+			die("%s", rerr)
+		}
+		payload := map[string]any{"content": string(content), "include_jobs": true}
+		if *dryRun {
+			payload["dry_run"] = true
+		}
+		if *ref != "" {
+			payload["ref"] = *ref
+		}
+		data, err = c.sendJSON("POST", endpoint, payload)
+	} else {
+		// content_ref/dry_run_ref replaced sha/ref in 16.x; send both.
+		params := url.Values{"include_jobs": {"true"}, "dry_run": {strconv.FormatBool(*dryRun)}}
+		if *ref != "" {
+			params.Set("content_ref", *ref)
+			params.Set("sha", *ref)
+			params.Set("dry_run_ref", *ref)
+			params.Set("ref", *ref)
+		}
+		data, err = c.get(endpoint, params)
+	}
+	if err != nil {
+		die("ci-lint: %v", err)
+	}
+	var r map[string]any
+	json.Unmarshal(data, &r)
+
+	valid := r["valid"] == true
+	what := strOr(*file, "repository config")
+	if valid {
+		fmt.Printf("✓ %s is valid\n", what)
+	} else {
+		fmt.Printf("✗ %s is invalid\n", what)
+	}
+	for _, e := range toStringSlice(jsonArr(r, "errors")) {
+		fmt.Printf("  error: %s\n", e)
+	}
+	for _, w := range toStringSlice(jsonArr(r, "warnings")) {
+		fmt.Printf("  warning: %s\n", w)
+	}
+	if inc := jsonArr(r, "includes"); len(inc) > 0 {
+		fmt.Println("\nIncludes:")
+		for _, i := range inc {
+			im := asMap(i)
+			fmt.Printf("  [%s] %s\n", jsonStr(im, "type"), strOr(jsonStr(im, "location"), jsonStr(im, "raw")))
+		}
+	}
+
+	if jobs := jsonArr(r, "jobs"); len(jobs) > 0 {
+		title := "Jobs"
+		if *dryRun {
+			title = fmt.Sprintf("Jobs that would run on %s", strOr(*ref, "the default branch"))
+		}
+		fmt.Printf("\n%s (%d):\n", title, len(jobs))
+		stage := ""
+		for _, j := range jobs {
+			jm := asMap(j)
+			if s := jsonStr(jm, "stage"); s != stage {
+				stage = s
+				fmt.Printf("  %s:\n", stage)
+			}
+			var extra []string
+			if w := jsonStr(jm, "when"); w != "" && w != "on_success" {
+				extra = append(extra, "when: "+w)
+			}
+			if jm["allow_failure"] == true {
+				extra = append(extra, "allow_failure")
+			}
+			if env := jsonStr(jm, "environment"); env != "" {
+				extra = append(extra, "env: "+env)
+			}
+			if tags := toStringSlice(jsonArr(jm, "tag_list")); len(tags) > 0 {
+				extra = append(extra, "tags: "+strings.Join(tags, ","))
+			}
+			line := "    " + jsonStr(jm, "name")
+			if len(extra) > 0 {
+				line += "  (" + strings.Join(extra, "; ") + ")"
+			}
+			fmt.Println(line)
+		}
+	} else if *dryRun && valid {
+		fmt.Println("\nNo jobs would run on this ref.")
+	}
+
+	if y := jsonStr(r, "merged_yaml"); y != "" && !*noYAML {
+		fmt.Println("\n--- merged YAML ---")
+		fmt.Print(y)
+		if !strings.HasSuffix(y, "\n") {
+			fmt.Println()
+		}
+	}
+	if !valid {
+		os.Exit(1)
+	}
+}
+
+// ── Commits API ─────────────────────────────────────────────
+
+// commitAction is one entry of a commits-API "actions" array.
+type commitAction struct {
+	Action       string `json:"action"`
+	FilePath     string `json:"file_path"`
+	PreviousPath string `json:"previous_path,omitempty"`
+	Content      string `json:"content,omitempty"`
+	Encoding     string `json:"encoding,omitempty"`
+	local        string
+}
+
+// actionFlag appends to a shared list so actions keep command-line order
+// across the different --create/--update/--delete/--move flags.
+type actionFlag struct {
+	kind string
+	list *[]commitAction
+}
+
+func (f actionFlag) String() string { return "" }
+
+func (f actionFlag) Set(v string) error {
+	a := commitAction{Action: f.kind}
+	spec, local, hasLocal := strings.Cut(v, "=")
+	if f.kind == "move" {
+		from, to, ok := strings.Cut(spec, ":")
+		if !ok || from == "" || to == "" {
+			return fmt.Errorf("--move wants old:new[=localfile], got %q", v)
+		}
+		a.PreviousPath, a.FilePath = from, to
+		if hasLocal {
+			a.local = local
+		}
+	} else {
+		a.FilePath = spec
+		if f.kind != "delete" {
+			a.local = spec
+			if hasLocal {
+				a.local = local
+			}
+		} else if hasLocal {
+			return fmt.Errorf("--delete takes only a path, got %q", v)
+		}
+	}
+	if a.FilePath == "" {
+		return fmt.Errorf("empty path in --%s", f.kind)
+	}
+	*f.list = append(*f.list, a)
+	return nil
+}
+
+// loadContent fills in an action's content from its local file, using
+// base64 for anything that is not valid UTF-8 text.
+func (a *commitAction) loadContent() error {
+	if a.local == "" {
+		return nil
+	}
+	data, err := os.ReadFile(a.local)
+	if err != nil {
+		return err
+	}
+	if utf8.Valid(data) && !bytes.ContainsRune(data, 0) {
+		a.Content = string(data)
+		a.Encoding = "text"
+	} else {
+		a.Content = base64.StdEncoding.EncodeToString(data)
+		a.Encoding = "base64"
+	}
+	return nil
+}
+
+// cmdCommit creates one atomic commit through the commits API, without a
+// local clone.
+//
+//	<host> commit <project> --branch b -m "msg" [--start-branch main]
+//	              [--create path[=localfile]] [--update path[=localfile]]
+//	              [--delete path] [--move old:new[=localfile]]
+//	              [--author-name N --author-email E] [--mr [--mr-title T]] [--dry-run]
+//
+// Actions are applied in command-line order. Without =localfile the
+// content is read from the same relative path locally. If the branch does
+// not exist and --start-branch is not given, it is created from the
+// project's default branch.
+func cmdCommit(c *apiClient, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		die("Usage: commit <project> --branch b -m \"msg\" [--create|--update path[=file]] [--delete path] [--move old:new[=file]] [--mr]")
+	}
+	project := args[0]
+	encoded := url.PathEscape(project)
+	fs := flag.NewFlagSet("commit", flag.ExitOnError)
+	var actions []commitAction
+	for _, kind := range []string{"create", "update", "delete", "move"} {
+		fs.Var(actionFlag{kind: kind, list: &actions}, kind, kind+" a file (repeatable)")
+	}
+	branch := fs.String("branch", "", "branch to commit to (required)")
+	startBranch := fs.String("start-branch", "", "create --branch from this branch")
+	var message string
+	fs.StringVar(&message, "m", "", "commit message (required)")
+	fs.StringVar(&message, "message", "", "commit message (required)")
+	authorName := fs.String("author-name", "", "commit author name")
+	authorEmail := fs.String("author-email", "", "commit author email")
+	openMR := fs.Bool("mr", false, "open a merge request from --branch afterwards")
+	mrTitle := fs.String("mr-title", "", "MR title (default: first line of the commit message)")
+	dryRun := fs.Bool("dry-run", false, "print the actions without committing")
+	_ = fs.Parse(args[1:])
+
+	if *branch == "" || message == "" {
+		die("commit: --branch and -m are required")
+	}
+	if len(actions) == 0 {
+		die("commit: no actions (use --create, --update, --delete or --move)")
+	}
+	for i := range actions {
+		if err := actions[i].loadContent(); err != nil {
+			die("commit: %v", err)
+		}
+	}
+
+	payload := map[string]any{
+		"branch":         *branch,
+		"commit_message": message,
+		"actions":        actions,
+	}
+	start := *startBranch
+	if start == "" {
+		if _, err := c.get("/projects/"+encoded+"/repository/branches/"+url.PathEscape(*branch), nil); err != nil {
+			var ae *apiError
+			if !errors.As(err, &ae) || ae.StatusCode != 404 {
+				die("%s", err)
+			}
+			data, err := c.get("/projects/"+encoded, nil)
+			if err != nil {
+				die("%s", err)
+			}
+			var pm map[string]any
+			json.Unmarshal(data, &pm)
+			start = strOr(jsonStr(pm, "default_branch"), "main")
+			fmt.Fprintf(os.Stderr, "branch %s does not exist; creating it from %s\n", *branch, start)
+		}
+	}
+	if start != "" {
+		payload["start_branch"] = start
+	}
+	if *authorName != "" {
+		payload["author_name"] = *authorName
+	}
+	if *authorEmail != "" {
+		payload["author_email"] = *authorEmail
+	}
+
+	if *dryRun {
+		fmt.Printf("Would commit to %s", *branch)
+		if start != "" {
+			fmt.Printf(" (from %s)", start)
+		}
+		fmt.Printf(": %s\n", strings.SplitN(message, "\n", 2)[0])
+		for _, a := range actions {
+			line := fmt.Sprintf("  %-6s %s", a.Action, a.FilePath)
+			if a.PreviousPath != "" {
+				line = fmt.Sprintf("  %-6s %s -> %s", a.Action, a.PreviousPath, a.FilePath)
+			}
+			if a.Encoding != "" {
+				line += fmt.Sprintf("  (%s, %d bytes from %s)", a.Encoding, len(a.Content), a.local)
+			}
+			fmt.Println(line)
+		}
+		return
+	}
+
+	data, err := c.sendJSON("POST", "/projects/"+encoded+"/repository/commits", payload)
+	if err != nil {
+		die("commit: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("committed %s to %s: %s\n  %s\n", jsonStr(m, "short_id"), *branch, jsonStr(m, "title"), jsonStr(m, "web_url"))
+
+	if *openMR {
+		title := strOr(*mrTitle, strings.SplitN(message, "\n", 2)[0])
+		mrArgs := []string{project, "--source", *branch, "--title", title}
+		if start != "" && start != *branch {
+			mrArgs = append(mrArgs, "--target", start)
+		}
+		cmdMRCreate(c, mrArgs)
+	}
+}
+
+// ── Releases ────────────────────────────────────────────────
+
+// cmdReleases lists a project's releases, newest first.
+//
+//	<host> releases <project> [limit]
+func cmdReleases(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: releases <project> [limit]")
+	}
+	encoded := url.PathEscape(args[0])
+	limit := "20"
+	if len(args) > 1 {
+		limit = args[1]
+	}
+	data, err := c.get("/projects/"+encoded+"/releases", url.Values{"per_page": {limit}})
+	if err != nil {
+		die("%s", err)
+	}
+	var rels []any
+	json.Unmarshal(data, &rels)
+	for _, r := range rels {
+		rm := asMap(r)
+		upcoming := ""
+		if rm["upcoming_release"] == true {
+			upcoming = " (upcoming)"
+		}
+		fmt.Printf("%s  %s%s\n", jsonStr(rm, "tag_name"), jsonStr(rm, "name"), upcoming)
+		fmt.Printf("  Released: %s  Author: %s\n", jsonStr(rm, "released_at"),
+			strOr(jsonStr(jsonMap(rm, "author"), "username"), "unknown"))
+		fmt.Printf("  %s\n\n", jsonStr(jsonMap(rm, "_links"), "self"))
+	}
+}
+
+// releaseCategories are the note sections in display order. Labels and
+// Conventional Commit types map onto them via categoryFor.
+var releaseCategories = []string{"Breaking changes", "Features", "Bug fixes", "Maintenance", "Other"}
+
+var conventionalRe = regexp.MustCompile(`^(\w+)(\([^)]*\))?(!)?:\s*(.+)$`)
+
+// conventionalType parses a Conventional Commit title into its type,
+// breaking flag, and subject. ok is false for non-conforming titles.
+func conventionalType(title string) (typ string, breaking bool, subject string, ok bool) {
+	m := conventionalRe.FindStringSubmatch(strings.TrimSpace(title))
+	if m == nil {
+		return "", false, title, false
+	}
+	return strings.ToLower(m[1]), m[3] == "!", m[4], true
+}
+
+func categoryFor(word string) string {
+	w := strings.ToLower(word)
+	if i := strings.LastIndex(w, "::"); i >= 0 {
+		w = w[i+2:]
+	}
+	switch w {
+	case "feature", "feat", "enhancement", "new feature":
+		return "Features"
+	case "bug", "fix", "bugfix", "regression", "security":
+		return "Bug fixes"
+	case "chore", "maintenance", "docs", "documentation", "refactor", "ci", "build",
+		"deps", "dependencies", "test", "tests", "perf", "performance", "style", "tooling":
+		return "Maintenance"
+	case "breaking", "breaking change", "breaking-change":
+		return "Breaking changes"
+	}
+	return ""
+}
+
+type noteEntry struct {
+	category, text, author string
+}
+
+// buildReleaseNotes groups MRs (by label, falling back to their titles)
+// and stray commits (by Conventional Commit type) into markdown notes.
+func buildReleaseNotes(tag, prev string, mrs []map[string]any, commits []map[string]any) string {
+	var entries []noteEntry
+	authors := map[string]bool{}
+	covered := map[string]bool{}
+	for _, mr := range mrs {
+		for _, k := range []string{"sha", "merge_commit_sha", "squash_commit_sha"} {
+			if s := jsonStr(mr, k); s != "" {
+				covered[s] = true
+			}
+		}
+		cat := ""
+		for _, l := range toStringSlice(jsonArr(mr, "labels")) {
+			if c := categoryFor(l); c != "" && (cat == "" || c == "Breaking changes") {
+				cat = c
+			}
+		}
+		title := jsonStr(mr, "title")
+		typ, breaking, subject, ok := conventionalType(title)
+		if ok {
+			title = subject
+			if cat == "" {
+				cat = categoryFor(typ)
+			}
+		}
+		if breaking {
+			cat = "Breaking changes"
+		}
+		author := ""
+		if u := jsonStr(jsonMap(mr, "author"), "username"); u != "" {
+			author = "@" + u
+			authors[author] = true
+		}
+		entries = append(entries, noteEntry{
+			category: strOr(cat, "Other"),
+			text:     fmt.Sprintf("%s ([!%s](%s))", title, jsonStr(mr, "iid"), jsonStr(mr, "web_url")),
+			author:   author,
+		})
+	}
+	for _, cm := range commits {
+		title := jsonStr(cm, "title")
+		if covered[jsonStr(cm, "id")] || strings.HasPrefix(title, "Merge branch") || strings.HasPrefix(title, "Merge remote-tracking") {
+			continue
+		}
+		if len(jsonArr(cm, "parent_ids")) > 1 {
+			continue
+		}
+		typ, breaking, subject, ok := conventionalType(title)
+		if !ok {
+			// Without MRs, every commit is a note; with MRs, only
+			// Conventional Commits are worth surfacing as direct pushes.
+			if len(mrs) > 0 {
+				continue
+			}
+			subject = title
+		}
+		cat := strOr(categoryFor(typ), "Other")
+		if breaking || strings.Contains(jsonStr(cm, "message"), "BREAKING CHANGE") {
+			cat = "Breaking changes"
+		}
+		author := jsonStr(cm, "author_name")
+		if author != "" {
+			authors[author] = true
+		}
+		entries = append(entries, noteEntry{category: cat, text: fmt.Sprintf("%s (%s)", subject, jsonStr(cm, "short_id")), author: author})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s (%s)\n\n", tag, time.Now().Format("2006-01-02"))
+	if prev != "" {
+		fmt.Fprintf(&b, "Changes since %s.\n\n", prev)
+	}
+	if len(entries) == 0 {
+		b.WriteString("No changes.\n")
+		return b.String()
+	}
+	for _, cat := range releaseCategories {
+		first := true
+		for _, e := range entries {
+			if e.category != cat {
+				continue
+			}
+			if first {
+				fmt.Fprintf(&b, "### %s\n\n", cat)
+				first = false
+			}
+			by := ""
+			if e.author != "" {
+				by = " — " + e.author
+			}
+			fmt.Fprintf(&b, "- %s%s\n", e.text, by)
+		}
+		if !first {
+			b.WriteString("\n")
+		}
+	}
+	var names []string
+	for a := range authors {
+		names = append(names, a)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(&b, "### Contributors\n\n%s\n", strings.Join(names, ", "))
+	return b.String()
+}
+
+// previousTag returns the newest release whose commit is older than
+// before, or the newest such tag when no release is, so backfilling notes
+// for an old tag compares against the release before it rather than a
+// later one. A zero before (tag not created yet) accepts every other tag.
+func previousTag(c *apiClient, encoded, tag string, before time.Time) string {
+	if items, err := c.getAll("/projects/"+encoded+"/releases", nil); err == nil {
+		if prev := newestTagBefore(items, "tag_name", tag, before); prev != "" {
+			return prev
+		}
+	}
+	items, err := c.getAll("/projects/"+encoded+"/repository/tags", nil)
+	if err != nil {
+		return ""
+	}
+	return newestTagBefore(items, "name", tag, before)
+}
+
+// newestTagBefore picks, from releases or tags (name under nameKey), the
+// one other than tag whose commit is the newest strictly before before.
+func newestTagBefore(items []any, nameKey, tag string, before time.Time) string {
+	best, bestAt := "", time.Time{}
+	for _, it := range items {
+		m := asMap(it)
+		name := jsonStr(m, nameKey)
+		at, err := time.Parse(time.RFC3339, jsonStr(jsonMap(m, "commit"), "committed_date"))
+		if name == "" || name == tag || err != nil {
+			continue
+		}
+		if !before.IsZero() && !at.Before(before) {
+			continue
+		}
+		if best == "" || at.After(bestAt) {
+			best, bestAt = name, at
+		}
+	}
+	return best
+}
+
+// cmdReleaseCreate creates a release whose notes are generated from the
+// MRs merged since the previous tag.
+//
+//	<host> release-create <project> <tag> [--ref main] [--from prev-tag] [--name N]
+//	                      [--notes-file extra.md] [--dry-run]
+//
+// MRs are grouped by label (feature/bug/chore style, scoped labels
+// allowed), falling back to Conventional Commit prefixes in MR titles;
+// direct-push commits are parsed as Conventional Commits.
+func cmdReleaseCreate(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: release-create <project> <tag> [--ref main] [--from prev-tag] [--dry-run]")
+	}
+	encoded := url.PathEscape(args[0])
+	tag := args[1]
+	fs := flag.NewFlagSet("release-create", flag.ExitOnError)
+	ref := fs.String("ref", "", "ref to tag when the tag does not exist yet (default: project default branch)")
+	from := fs.String("from", "", "previous tag (default: newest release, or tag, older than <tag>)")
+	name := fs.String("name", "", "release name (default: the tag)")
+	notesFile := fs.String("notes-file", "", "markdown prepended to the generated notes")
+	dryRun := fs.Bool("dry-run", false, "print the notes without creating the release")
+	_ = fs.Parse(args[2:])
+
+	tagExists := false
+	var tagDate time.Time
+	if data, err := c.get("/projects/"+encoded+"/repository/tags/"+url.PathEscape(tag), nil); err == nil {
+		tagExists = true
+		var tm map[string]any
+		json.Unmarshal(data, &tm)
+		tagDate, _ = time.Parse(time.RFC3339, jsonStr(jsonMap(tm, "commit"), "committed_date"))
+	}
+	to := tag
+	branch := *ref
+	if !tagExists {
+		if branch == "" {
+			data, err := c.get("/projects/"+encoded, nil)
+			if err != nil {
+				die("%s", err)
+			}
+			var pm map[string]any
+			json.Unmarshal(data, &pm)
+			branch = strOr(jsonStr(pm, "default_branch"), "main")
+		}
+		to = branch
+	}
+	prev := *from
+	if prev == "" {
+		prev = previousTag(c, encoded, tag, tagDate)
+	}
+
+	var commits []map[string]any
+	if prev != "" {
+		data, err := c.get("/projects/"+encoded+"/repository/compare", url.Values{"from": {prev}, "to": {to}})
+		if err != nil {
+			die("compare %s...%s: %v", prev, to, err)
+		}
+		var cmp map[string]any
+		json.Unmarshal(data, &cmp)
+		for _, cm := range jsonArr(cmp, "commits") {
+			commits = append(commits, asMap(cm))
+		}
+	} else {
+		items, err := c.getAll("/projects/"+encoded+"/repository/commits", url.Values{"ref_name": {to}})
+		if err != nil {
+			die("%s", err)
+		}
+		for _, cm := range items {
+			commits = append(commits, asMap(cm))
+		}
+	}
+	inRange := map[string]bool{}
+	oldest := ""
+	for _, cm := range commits {
+		inRange[jsonStr(cm, "id")] = true
+		if d := jsonStr(cm, "committed_date"); oldest == "" || d < oldest {
+			oldest = d
+		}
+	}
+
+	var mrs []map[string]any
+	if len(commits) > 0 {
+		params := url.Values{"state": {"merged"}, "order_by": {"updated_at"}, "sort": {"desc"}}
+		if oldest != "" {
+			params.Set("updated_after", oldest)
+		}
+		items, err := c.getAll("/projects/"+encoded+"/merge_requests", params)
+		if err != nil {
+			die("%s", err)
+		}
+		for _, it := range items {
+			mr := asMap(it)
+			if inRange[jsonStr(mr, "merge_commit_sha")] || inRange[jsonStr(mr, "squash_commit_sha")] || inRange[jsonStr(mr, "sha")] {
+				mrs = append(mrs, mr)
+			}
+		}
+		sort.Slice(mrs, func(i, j int) bool { return jsonStr(mrs[i], "merged_at") < jsonStr(mrs[j], "merged_at") })
+	}
+
+	notes := buildReleaseNotes(tag, prev, mrs, commits)
+	if *notesFile != "" {
+		extra, err := os.ReadFile(*notesFile)
+		if err != nil {
+			die("%s", err)
+		}
+		notes = strings.TrimRight(string(extra), "\n") + "\n\n" + notes
+	}
+	if *dryRun {
+		fmt.Print(notes)
+		fmt.Fprintf(os.Stderr, "(dry run: %d MR(s), %d commit(s) in %s...%s; nothing created)\n", len(mrs), len(commits), strOr(prev, "<start>"), to)
+		return
+	}
+
+	payload := map[string]any{
+		"tag_name":    tag,
+		"name":        strOr(*name, tag),
+		"description": notes,
+	}
+	if !tagExists {
+		payload["ref"] = branch
+	}
+	data, err := c.sendJSON("POST", "/projects/"+encoded+"/releases", payload)
+	if err != nil {
+		die("release-create: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("release %s created (%d MR(s), %d commit(s) since %s)\n  %s\n", jsonStr(m, "tag_name"),
+		len(mrs), len(commits), strOr(prev, "the beginning"), jsonStr(jsonMap(m, "_links"), "self"))
+}
+
+// ── Group hygiene ───────────────────────────────────────────
+
+type staleBranch struct {
+	name, date, author string
+	action             string // cleanup outcome for merged branches
+}
+
+type staleReport struct {
+	project, defaultBranch string
+	stale, merged          []staleBranch
+	mrs                    []map[string]any
+	err                    error
+	deleted, failed        int
+	wouldDelete            int
+}
+
+// groupProjects returns every non-archived project in a group and its
+// subgroups.
+func groupProjects(c *apiClient, group string) ([]map[string]any, error) {
+	items, err := c.getAll("/groups/"+url.PathEscape(group)+"/projects", url.Values{
+		"include_subgroups": {"true"}, "archived": {"false"}, "order_by": {"path"}, "sort": {"asc"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var projects []map[string]any
+	for _, it := range items {
+		if pm := asMap(it); pm != nil {
+			projects = append(projects, pm)
+		}
+	}
+	return projects, nil
+}
+
+// forEachLimited calls fn(0..n-1) with at most limit calls in flight and
+// returns when all have finished.
+func forEachLimited(n, limit int, fn func(i int)) {
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// scanStale classifies one project's unprotected, non-default branches as
+// merged or stale and collects open MRs untouched since cutoff. Merged
+// branches are deleted when deleteMerged is set and dryRun is not.
+func scanStale(c *apiClient, pm map[string]any, cutoff time.Time, deleteMerged, dryRun bool) staleReport {
+	r := staleReport{
+		project:       jsonStr(pm, "path_with_namespace"),
+		defaultBranch: jsonStr(pm, "default_branch"),
+	}
+	id := jsonStr(pm, "id")
+	mrs, err := c.getAll("/projects/"+id+"/merge_requests", url.Values{
+		"state": {"opened"}, "order_by": {"updated_at"}, "sort": {"asc"},
+	})
+	if err != nil {
+		r.err = err
+		return r
+	}
+	openSources := map[string]string{} // branch → iid of an open MR using it
+	for _, m := range mrs {
+		mm := asMap(m)
+		if src := jsonStr(mm, "source_project_id"); src == "" || src == id {
+			openSources[jsonStr(mm, "source_branch")] = jsonStr(mm, "iid")
+		}
+		if t, err := time.Parse(time.RFC3339, jsonStr(mm, "updated_at")); err == nil && t.Before(cutoff) {
+			r.mrs = append(r.mrs, mm)
+		}
+	}
+	if jsonStr(pm, "empty_repo") != "true" {
+		branches, err := c.getAll("/projects/"+id+"/repository/branches", nil)
+		if err != nil {
+			r.err = err
+			return r
+		}
+		for _, b := range branches {
+			bm := asMap(b)
+			name := jsonStr(bm, "name")
+			if bm["default"] == true || bm["protected"] == true || name == r.defaultBranch {
+				continue
+			}
+			commit := jsonMap(bm, "commit")
+			sb := staleBranch{name: name, date: jsonStr(commit, "committed_date"), author: jsonStr(commit, "author_name")}
+			if bm["merged"] == true {
+				r.merged = append(r.merged, sb)
+				continue
+			}
+			if t, err := time.Parse(time.RFC3339, sb.date); err == nil && t.Before(cutoff) {
+				r.stale = append(r.stale, sb)
+			}
+		}
+	}
+	if !deleteMerged {
+		return r
+	}
+	// A branch with no commits of its own also reports merged, so only
+	// delete merged branches that are old and not the source of an open MR.
+	for i, b := range r.merged {
+		if t, err := time.Parse(time.RFC3339, b.date); err != nil || !t.Before(cutoff) {
+			r.merged[i].action = "kept: recent commits"
+			continue
+		}
+		if iid, ok := openSources[b.name]; ok {
+			r.merged[i].action = "kept: source of open !" + iid
+			continue
+		}
+		if dryRun {
+			r.wouldDelete++
+			r.merged[i].action = "would delete"
+			continue
+		}
+		if err := c.delete("/projects/"+id+"/repository/branches/"+url.PathEscape(r.merged[i].name), nil); err != nil {
+			r.merged[i].action = "delete failed: " + strings.TrimSpace(err.Error())
+			r.failed++
+			continue
+		}
+		r.merged[i].action = "deleted"
+		r.deleted++
+	}
+	return r
+}
+
+// cmdStale reports branch and MR hygiene across every project in a group,
+// recursing into subgroups.
+//
+//	<host> stale <group> [--days 90] [--concurrency 4] [--delete-merged [--dry-run]]
+//
+// Default and protected branches are never reported or deleted. A branch
+// is "merged" when GitLab reports it fully merged into the default branch,
+// and "stale" when its head commit is older than --days. --delete-merged
+// only deletes merged branches whose head commit is also older than --days
+// and that are not the source branch of an open MR.
+func cmdStale(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: stale <group> [--days 90] [--concurrency 4] [--delete-merged [--dry-run]]")
+	}
+	group := args[0]
+	fs := flag.NewFlagSet("stale", flag.ExitOnError)
+	days := fs.Int("days", 90, "inactivity threshold in days for branches and open MRs")
+	concurrency := fs.Int("concurrency", 4, "projects scanned in parallel")
+	deleteMerged := fs.Bool("delete-merged", false, "delete branches already merged into the default branch")
+	dryRun := fs.Bool("dry-run", false, "with --delete-merged, list what would be deleted")
+	_ = fs.Parse(args[1:])
+	if *dryRun && !*deleteMerged {
+		die("stale: --dry-run only applies with --delete-merged")
+	}
+
+	projects, err := groupProjects(c, group)
+	if err != nil {
+		die("%s", err)
+	}
+	cutoff := time.Now().AddDate(0, 0, -*days)
+	reports := make([]staleReport, len(projects))
+	forEachLimited(len(projects), *concurrency, func(i int) {
+		reports[i] = scanStale(c, projects[i], cutoff, *deleteMerged, *dryRun)
+	})
+
+	var withFindings, nStale, nMerged, nMRs, nDeleted, nFailed, nErrors, nWould int
+	for _, r := range reports {
+		nWould += r.wouldDelete
+		nStale += len(r.stale)
+		nMerged += len(r.merged)
+		nMRs += len(r.mrs)
+		nDeleted += r.deleted
+		nFailed += r.failed
+		if r.err != nil {
+			nErrors++
+			fmt.Printf("%s\n  error: %v\n\n", r.project, r.err)
+			continue
+		}
+		if len(r.stale)+len(r.merged)+len(r.mrs) == 0 {
+			continue
+		}
+		withFindings++
+		fmt.Printf("%s (default: %s)\n", r.project, strOr(r.defaultBranch, "none"))
+		if len(r.merged) > 0 {
+			fmt.Printf("  Merged into %s (%d):\n", r.defaultBranch, len(r.merged))
+			for _, b := range r.merged {
+				action := ""
+				if b.action != "" {
+					action = "  [" + b.action + "]"
+				}
+				fmt.Printf("    %-40s %s  %s%s\n", b.name, shortDate(b.date), b.author, action)
+			}
+		}
+		if len(r.stale) > 0 {
+			fmt.Printf("  No commits in %dd (%d):\n", *days, len(r.stale))
+			for _, b := range r.stale {
+				fmt.Printf("    %-40s %s  %s\n", b.name, shortDate(b.date), b.author)
+			}
+		}
+		if len(r.mrs) > 0 {
+			fmt.Printf("  Open MRs inactive %dd (%d):\n", *days, len(r.mrs))
+			for _, m := range r.mrs {
+				fmt.Printf("    !%s %s  updated %s  @%s\n", jsonStr(m, "iid"), jsonStr(m, "title"),
+					shortDate(jsonStr(m, "updated_at")), jsonStr(jsonMap(m, "author"), "username"))
+			}
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Summary: %d project(s) scanned in %s, %d with findings", len(projects), group, withFindings)
+	if nErrors > 0 {
+		fmt.Printf(", %d failed to scan", nErrors)
+	}
+	fmt.Printf("\n  Merged branches: %d  Stale branches (>%dd): %d  Inactive MRs: %d\n", nMerged, *days, nStale, nMRs)
+	switch {
+	case *deleteMerged && *dryRun:
+		fmt.Printf("  Dry run: %d merged branch(es) would be deleted\n", nWould)
+	case *deleteMerged:
+		fmt.Printf("  Deleted: %d  Failed: %d\n", nDeleted, nFailed)
+	}
+	if nFailed > 0 || nErrors > 0 {
+		os.Exit(1)
+	}
+}
+
+// shortDate trims an ISO 8601 timestamp to its date.
+func shortDate(s string) string {
+	if len(s) >= 10 {
+		return s[:10]
+	}
+	return s
+}
+
+// ── Policy audit ────────────────────────────────────────────
+
+type severity int
+
+const (
+	sevHigh severity = iota
+	sevMed
+	sevLow
+)
+
+func (s severity) String() string {
+	switch s {
+	case sevHigh:
+		return "HIGH"
+	case sevMed:
+		return "MED"
+	default:
+		return "LOW"
+	}
+}
+
+func parseSeverity(s string) (severity, error) {
+	switch strings.ToUpper(s) {
+	case "HIGH":
+		return sevHigh, nil
+	case "MED", "MEDIUM":
+		return sevMed, nil
+	case "LOW":
+		return sevLow, nil
+	}
+	return sevLow, fmt.Errorf("unknown severity %q (want HIGH, MED or LOW)", s)
+}
+
+type finding struct {
+	sev      severity
+	category string
+	path     string
+	message  string
+}
+
+type report struct {
+	findings []finding
+	stats    []kv
+	mu       sync.Mutex
+}
+
+type kv struct {
+	k, v string
+}
+
+func (r *report) add(sev severity, cat, path, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.findings = append(r.findings, finding{sev, cat, path, msg})
+}
+
+func (r *report) stat(k, v string) {
+	r.stats = append(r.stats, kv{k, v})
+}
+
+// auditPolicy declares what every project in a group is expected to have.
+// Unset fields are not checked.
+type auditPolicy struct {
+	DefaultBranchProtected *bool             `json:"default_branch_protected"`
+	AllowForcePush         *bool             `json:"allow_force_push"`
+	MinApprovals           *int              `json:"min_approvals"`
+	PipelinesMustSucceed   *bool             `json:"pipelines_must_succeed"`
+	Visibility             []string          `json:"visibility"`
+	ContainerScanning      *bool             `json:"container_scanning"`
+	Exclude                []string          `json:"exclude"`
+	Severity               map[string]string `json:"severity"`
+
+	severities map[string]severity // defaultAuditSeverity with Severity applied
+}
+
+// Default severities per check; a policy's severity map overrides them.
+var defaultAuditSeverity = map[string]severity{
+	"default_branch_protected": sevHigh,
+	"allow_force_push":         sevHigh,
+	"visibility":               sevHigh,
+	"min_approvals":            sevMed,
+	"pipelines_must_succeed":   sevMed,
+	"container_scanning":       sevLow,
+}
+
+// loadPolicy reads a policy as JSON or as the YAML subset understood by
+// parseYAMLSubset. Unknown keys are rejected so typos can't silently
+// disable a check.
+func loadPolicy(path string) (*auditPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(raw, []byte("{")) {
+		m, err := parseYAMLSubset(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if raw, err = json.Marshal(m); err != nil {
+			return nil, err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var p auditPolicy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.severities = map[string]severity{}
+	for check, sev := range defaultAuditSeverity {
+		p.severities[check] = sev
+	}
+	for check, sev := range p.Severity {
+		if _, ok := defaultAuditSeverity[check]; !ok {
+			return nil, fmt.Errorf("%s: severity for unknown check %q", path, check)
+		}
+		s, err := parseSeverity(sev)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		p.severities[check] = s
+	}
+	return &p, nil
+}
+
+// parseYAMLSubset handles the YAML a policy file needs: top-level
+// "key: scalar", inline lists ("[a, b]"), and one nested level of block
+// lists ("- item") or maps ("k: v"). Comments start with '#'.
+func parseYAMLSubset(src string) (map[string]any, error) {
+	out := map[string]any{}
+	pending := ""
+	for n, line := range strings.Split(src, "\n") {
+		line = stripYAMLComment(line)
+		if strings.TrimSpace(line) == "" || strings.TrimSpace(line) == "---" {
+			continue
+		}
+		text := strings.TrimSpace(line)
+		if line[0] != ' ' && line[0] != '\t' {
+			key, val, ok := strings.Cut(text, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected \"key: value\"", n+1)
+			}
+			key = strings.TrimSpace(key)
+			val = strings.TrimSpace(val)
+			if val == "" {
+				pending = key
+				out[key] = nil
+				continue
+			}
+			pending = ""
+			out[key] = yamlScalar(val)
+			continue
+		}
+		if pending == "" {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n+1)
+		}
+		if item, ok := strings.CutPrefix(text, "- "); ok || text == "-" {
+			list, _ := out[pending].([]any)
+			if out[pending] != nil && list == nil {
+				return nil, fmt.Errorf("line %d: list item inside a map", n+1)
+			}
+			out[pending] = append(list, yamlScalar(strings.TrimSpace(item)))
+			continue
+		}
+		key, val, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\" or \"- item\"", n+1)
+		}
+		m, _ := out[pending].(map[string]any)
+		if m == nil {
+			if out[pending] != nil {
+				return nil, fmt.Errorf("line %d: map entry inside a list", n+1)
+			}
+			m = map[string]any{}
+			out[pending] = m
+		}
+		m[strings.TrimSpace(key)] = yamlScalar(strings.TrimSpace(val))
+	}
+	return out, nil
+}
+
+// stripYAMLComment drops a '#' comment that starts the line or follows
+// whitespace, ignoring any '#' inside a quoted scalar.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[,", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func yamlScalar(s string) any {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		list := []any{}
+		for _, part := range strings.Split(s[1:len(s)-1], ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, yamlScalar(part))
+			}
+		}
+		return list
+	}
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	case "null", "~":
+		return nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// auditProject runs every check the policy declares against one project.
+// Checks the instance does not offer (a 404 from a tier-limited endpoint,
+// or no CI config) are reported as LOW "unverified" findings. Any other
+// failure (auth, permissions, network) is a HIGH "error" finding, so a
+// token that cannot see a setting never lets the audit pass.
+func auditProject(c *apiClient, pm map[string]any, p *auditPolicy, rep *report) {
+	path := jsonStr(pm, "path_with_namespace")
+	id := jsonStr(pm, "id")
+	branch := jsonStr(pm, "default_branch")
+	fail := func(check, msg string) { rep.add(p.severities[check], check, path, msg) }
+	cannotCheck := func(check string, err error) {
+		msg := fmt.Sprintf("%s: %s", check, strings.TrimSpace(err.Error()))
+		var ae *apiError
+		if (errors.As(err, &ae) && ae.StatusCode == 404) || errors.Is(err, errNoCIConfig) {
+			rep.add(sevLow, "unverified", path, msg)
+			return
+		}
+		rep.add(sevHigh, "error", path, msg)
+	}
+
+	if len(p.Visibility) > 0 {
+		vis := jsonStr(pm, "visibility")
+		allowed := false
+		for _, v := range p.Visibility {
+			allowed = allowed || strings.EqualFold(v, vis)
+		}
+		if !allowed {
+			fail("visibility", fmt.Sprintf("visibility is %s; policy allows %s", vis, strings.Join(p.Visibility, ", ")))
+		}
+	}
+	if p.PipelinesMustSucceed != nil && *p.PipelinesMustSucceed && pm["only_allow_merge_if_pipeline_succeeds"] != true {
+		fail("pipelines_must_succeed", "MRs can merge without a successful pipeline (merge check \"Pipelines must succeed\" is off)")
+	}
+
+	if (p.DefaultBranchProtected != nil || p.AllowForcePush != nil) && branch != "" {
+		data, err := c.get("/projects/"+id+"/protected_branches/"+url.PathEscape(branch), nil)
+		var ae *apiError
+		switch {
+		case errors.As(err, &ae) && ae.StatusCode == 404:
+			if p.DefaultBranchProtected != nil && *p.DefaultBranchProtected {
+				fail("default_branch_protected", fmt.Sprintf("default branch %s is not protected", branch))
+			}
+			if p.AllowForcePush != nil && !*p.AllowForcePush {
+				fail("allow_force_push", fmt.Sprintf("default branch %s is unprotected, so anyone with push access can force-push", branch))
+			}
+		case err != nil:
+			cannotCheck("default_branch_protected", err)
+		default:
+			var pb map[string]any
+			json.Unmarshal(data, &pb)
+			if p.AllowForcePush != nil && !*p.AllowForcePush && pb["allow_force_push"] == true {
+				fail("allow_force_push", fmt.Sprintf("force push is allowed on protected branch %s", branch))
+			}
+		}
+	}
+
+	if p.MinApprovals != nil && *p.MinApprovals > 0 {
+		required, err := requiredApprovals(c, id)
+		switch {
+		case err != nil:
+			cannotCheck("min_approvals", err)
+		case required < *p.MinApprovals:
+			fail("min_approvals", fmt.Sprintf("MRs require %d approval(s); policy requires %d", required, *p.MinApprovals))
+		}
+	}
+
+	if p.ContainerScanning != nil && *p.ContainerScanning {
+		if branch == "" {
+			fail("container_scanning", "empty repository, no CI configuration")
+		} else if ok, err := hasContainerScanning(c, id, branch); err != nil {
+			cannotCheck("container_scanning", err)
+		} else if !ok {
+			fail("container_scanning", fmt.Sprintf("no container_scanning job in the merged CI config on %s", branch))
+		}
+	}
+}
+
+// requiredApprovals returns the highest approvals_required across the
+// project's approval rules, falling back to the legacy approvals setting
+// when the instance has no approval rules endpoint.
+func requiredApprovals(c *apiClient, id string) (int, error) {
+	data, err := c.get("/projects/"+id+"/approval_rules", nil)
+	if err == nil {
+		var rules []any
+		json.Unmarshal(data, &rules)
+		most := 0
+		for _, r := range rules {
+			if n := int(jsonNum(asMap(r), "approvals_required")); n > most {
+				most = n
+			}
+		}
+		return most, nil
+	}
+	var ae *apiError
+	if !errors.As(err, &ae) || ae.StatusCode != 404 {
+		return 0, err
+	}
+	data, err = c.get("/projects/"+id+"/approvals", nil)
+	if err != nil {
+		return 0, err
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	return int(jsonNum(m, "approvals_before_merge")), nil
+}
+
+// errNoCIConfig marks projects whose CI config is missing or invalid, so
+// container scanning cannot be verified either way.
+var errNoCIConfig = errors.New("CI config is invalid or missing")
+
+// hasContainerScanning lints the project's CI config on ref and looks
+// for a container_scanning job, as added by the
+// Jobs/Container-Scanning.gitlab-ci.yml template.
+func hasContainerScanning(c *apiClient, id, ref string) (bool, error) {
+	data, err := c.get("/projects/"+id+"/ci/lint", url.Values{"content_ref": {ref}, "include_jobs": {"true"}})
+	if err != nil {
+		return false, err
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	if m["valid"] == false {
+		return false, fmt.Errorf("%w on %s", errNoCIConfig, ref)
+	}
+	for _, j := range jsonArr(m, "jobs") {
+		if strings.Contains(jsonStr(asMap(j), "name"), "container_scanning") {
+			return true, nil
+		}
+	}
+	return strings.Contains(jsonStr(m, "merged_yaml"), "container_scanning:"), nil
+}
+
+func renderAudit(rep *report) string {
+	var b strings.Builder
+	b.WriteString(strings.Repeat("=", 60))
+	b.WriteString("\nGITLAB POLICY AUDIT\n")
+	b.WriteString(strings.Repeat("=", 60))
+	b.WriteByte('\n')
+
+	if len(rep.stats) > 0 {
+		b.WriteString("\nInventory:\n")
+		for _, s := range rep.stats {
+			fmt.Fprintf(&b, "  %s: %s\n", s.k, s.v)
+		}
+	}
+
+	if len(rep.findings) == 0 {
+		b.WriteString("\nNo findings. Every audited project meets the policy.\n")
+		return b.String()
+	}
+
+	var current severity = -1
+	for _, f := range rep.findings {
+		if f.sev != current {
+			current = f.sev
+			fmt.Fprintf(&b, "\n[%s]\n", f.sev)
+		}
+		fmt.Fprintf(&b, "  %s  %s\n    -> %s\n", f.category, f.path, f.message)
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// cmdAudit checks every project in a group (and its subgroups) against a
+// policy file and reports HIGH/MED/LOW findings.
+//
+//	<host> audit <group> --policy policy.yaml [--json] [--fail-on LOW] [--concurrency 4]
+//
+// Exits 1 when any finding other than "unverified" is at or above
+// --fail-on; "error" findings (a check failed on auth or network) are
+// HIGH, so they always fail the run.
+func cmdAudit(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: audit <group> --policy policy.yaml [--json] [--fail-on LOW|MED|HIGH]")
+	}
+	group := args[0]
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	policyFile := fs.String("policy", "", "policy file (YAML subset or JSON)")
+	asJSON := fs.Bool("json", false, "emit findings as JSON")
+	failOn := fs.String("fail-on", "LOW", "lowest severity that makes the exit status non-zero")
+	concurrency := fs.Int("concurrency", 4, "projects audited in parallel")
+	_ = fs.Parse(args[1:])
+	if *policyFile == "" {
+		die("audit: --policy is required")
+	}
+	threshold, err := parseSeverity(*failOn)
+	if err != nil {
+		die("audit: %v", err)
+	}
+	policy, err := loadPolicy(*policyFile)
+	if err != nil {
+		die("audit: %v", err)
+	}
+
+	all, err := groupProjects(c, group)
+	if err != nil {
+		die("%s", err)
+	}
+	var projects []map[string]any
+	excluded := 0
+	for _, pm := range all {
+		skip := false
+		for _, pat := range policy.Exclude {
+			if ok, _ := filepath.Match(pat, jsonStr(pm, "path_with_namespace")); ok {
+				skip = true
+			}
+		}
+		if skip {
+			excluded++
+			continue
+		}
+		projects = append(projects, pm)
+	}
+
+	var rep report
+	rep.stat("group", group)
+	rep.stat("policy", *policyFile)
+	rep.stat("projects audited", strconv.Itoa(len(projects)))
+	if excluded > 0 {
+		rep.stat("projects excluded", strconv.Itoa(excluded))
+	}
+	forEachLimited(len(projects), *concurrency, func(i int) {
+		auditProject(c, projects[i], policy, &rep)
+	})
+	sort.SliceStable(rep.findings, func(i, j int) bool {
+		a, b := rep.findings[i], rep.findings[j]
+		if a.sev != b.sev {
+			return a.sev < b.sev
+		}
+		if a.category != b.category {
+			return a.category < b.category
+		}
+		return a.path < b.path
+	})
+
+	// Unverified checks are reported but never fail the run: on tiers
+	// without approval rules they would otherwise fail every project.
+	violations := 0
+	for _, f := range rep.findings {
+		if f.sev <= threshold && f.category != "unverified" {
+			violations++
+		}
+	}
+	if *asJSON {
+		type jsonFinding struct {
+			Severity string `json:"severity"`
+			Check    string `json:"check"`
+			Project  string `json:"project"`
+			Message  string `json:"message"`
+		}
+		out := struct {
+			Group     string        `json:"group"`
+			Projects  int           `json:"projects"`
+			Excluded  int           `json:"excluded"`
+			Findings  []jsonFinding `json:"findings"`
+			Violation bool          `json:"violation"`
+		}{Group: group, Projects: len(projects), Excluded: excluded, Findings: []jsonFinding{}, Violation: violations > 0}
+		for _, f := range rep.findings {
+			out.Findings = append(out.Findings, jsonFinding{f.sev.String(), f.category, f.path, f.message})
+		}
+		printJSON(out)
+	} else {
+		fmt.Print(renderAudit(&rep))
+	}
+	if violations > 0 {
+		os.Exit(1)
+	}
+}
+
+// ── Issue authoring ─────────────────────────────────────────
+
+func printIssueResult(verb string, data json.RawMessage) {
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("#%s %s: %s\n", jsonStr(m, "iid"), verb, jsonStr(m, "title"))
+	details := []string{jsonStr(m, "state")}
+	if ms := jsonMap(m, "milestone"); ms != nil {
+		details = append(details, "milestone "+jsonStr(ms, "title"))
+	}
+	if d := jsonStr(m, "due_date"); d != "" {
+		details = append(details, "due "+d)
+	}
+	if w := jsonStr(m, "weight"); w != "" {
+		details = append(details, "weight "+w)
+	}
+	if labels := toStringSlice(jsonArr(m, "labels")); len(labels) > 0 {
+		details = append(details, "labels "+strings.Join(labels, ","))
+	}
+	fmt.Printf("  [%s]\n", strings.Join(details, "  "))
+	fmt.Printf("  %s\n", jsonStr(m, "web_url"))
+}
+
+// milestoneID resolves a milestone title (project or ancestor group) or
+// numeric ID to the global ID the issues API expects. base is a
+// "/projects/x" or "/groups/x" prefix.
+func milestoneID(c *apiClient, base, milestone string) (string, error) {
+	if _, err := strconv.Atoi(milestone); err == nil {
+		return milestone, nil
+	}
+	m, err := findMilestone(c, base, milestone)
+	if err != nil {
+		return "", err
+	}
+	return jsonStr(m, "id"), nil
+}
+
+// findMilestone looks up a milestone by title in base or its ancestor
+// groups.
+func findMilestone(c *apiClient, base, title string) (map[string]any, error) {
+	data, err := c.get(base+"/milestones", url.Values{
+		"title": {title}, "include_ancestors": {"true"}, "include_parent_milestones": {"true"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ms []any
+	json.Unmarshal(data, &ms)
+	if len(ms) == 0 {
+		return nil, fmt.Errorf("no milestone titled %q", title)
+	}
+	return asMap(ms[0]), nil
+}
+
+// issueForm maps the flags shared by issue-create and issue-update onto
+// API form fields. Only flags in set are applied; empty values clear.
+func issueForm(c *apiClient, encoded string, form url.Values, set map[string]bool, vals map[string]string) {
+	if set["assignees"] {
+		ids, err := userIDs(c, splitCSV(vals["assignees"]))
+		if err != nil {
+			die("assignees: %v", err)
+		}
+		setUserIDs(form, "assignee_ids", ids)
+	}
+	if set["labels"] {
+		form.Set("labels", strings.Join(splitCSV(vals["labels"]), ","))
+	}
+	if set["milestone"] {
+		id := "0"
+		if vals["milestone"] != "" {
+			var err error
+			if id, err = milestoneID(c, "/projects/"+encoded, vals["milestone"]); err != nil {
+				die("milestone: %v", err)
+			}
+		}
+		form.Set("milestone_id", id)
+	}
+	if set["due"] {
+		if d := vals["due"]; d != "" {
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				die("due: want YYYY-MM-DD, got %q", d)
+			}
+		}
+		form.Set("due_date", vals["due"])
+	}
+	if set["weight"] {
+		form.Set("weight", vals["weight"])
+	}
+	if set["confidential"] {
+		form.Set("confidential", vals["confidential"])
+	}
+}
+
+// cmdIssueCreate opens an issue.
+//
+//	<host> issue-create <project> --title "..." [--desc "..." | --desc-file path | --desc-stdin]
+//	                    [--labels x,y] [--assignees a,b] [--milestone "v1.2"]
+//	                    [--due 2026-11-30] [--weight 3] [--confidential] [--type issue|incident|task]
+func cmdIssueCreate(c *apiClient, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		die("Usage: issue-create <project> --title \"...\" [flags]")
+	}
+	encoded := url.PathEscape(args[0])
+	fs := flag.NewFlagSet("issue-create", flag.ExitOnError)
+	title := fs.String("title", "", "issue title (required)")
+	desc := fs.String("desc", "", "description text")
+	descFile := fs.String("desc-file", "", "read description from file")
+	descStdin := fs.Bool("desc-stdin", false, "read description from stdin")
+	labels := fs.String("labels", "", "comma-separated labels")
+	assignees := fs.String("assignees", "", "comma-separated assignee usernames")
+	milestone := fs.String("milestone", "", "milestone title or ID")
+	due := fs.String("due", "", "due date (YYYY-MM-DD)")
+	weight := fs.String("weight", "", "issue weight")
+	confidential := fs.Bool("confidential", false, "create as confidential")
+	issueType := fs.String("type", "", "issue, incident, or task")
+	_ = fs.Parse(args[1:])
+	set := flagsSet(fs)
+
+	if *title == "" {
+		die("issue-create: --title is required")
+	}
+	form := url.Values{"title": {*title}}
+	if body := readBody(*desc, *descFile, *descStdin); body != "" {
+		form.Set("description", body)
+	}
+	if *issueType != "" {
+		form.Set("issue_type", *issueType)
+	}
+	if *confidential {
+		form.Set("confidential", "true")
+	}
+	delete(set, "confidential") // a bool here; issueForm expects true|false text
+	issueForm(c, encoded, form, set, map[string]string{
+		"assignees": *assignees, "labels": *labels, "milestone": *milestone,
+		"due": *due, "weight": *weight,
+	})
+
+	data, err := c.post("/projects/"+encoded+"/issues", form)
+	if err != nil {
+		die("issue-create: %v", err)
+	}
+	printIssueResult("created", data)
+}
+
+// cmdIssueUpdate edits an existing issue. Only flags that are passed are
+// changed; --desc "", --assignees "", --milestone "" and --due "" clear
+// the field.
+//
+//	<host> issue-update <project> <iid> [--title] [--desc...] [--labels x,y]
+//	                    [--add-labels x] [--remove-labels y] [--assignees a,b]
+//	                    [--milestone "v1.2"] [--due 2026-11-30] [--weight 3]
+//	                    [--confidential true|false]
+func cmdIssueUpdate(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: issue-update <project> <iid> [flags]")
+	}
+	encoded := url.PathEscape(args[0])
+	issueIID := args[1]
+	fs := flag.NewFlagSet("issue-update", flag.ExitOnError)
+	title := fs.String("title", "", "new title")
+	desc := fs.String("desc", "", "new description")
+	descFile := fs.String("desc-file", "", "read new description from file")
+	descStdin := fs.Bool("desc-stdin", false, "read new description from stdin")
+	labels := fs.String("labels", "", "replace labels")
+	addLabels := fs.String("add-labels", "", "labels to add")
+	removeLabels := fs.String("remove-labels", "", "labels to remove")
+	assignees := fs.String("assignees", "", "replace assignees (comma-separated usernames; empty clears)")
+	milestone := fs.String("milestone", "", "milestone title or ID (empty clears)")
+	due := fs.String("due", "", "due date YYYY-MM-DD (empty clears)")
+	weight := fs.String("weight", "", "issue weight (empty clears)")
+	confidential := fs.String("confidential", "", "true|false")
+	_ = fs.Parse(args[2:])
+	set := flagsSet(fs)
+
+	form := url.Values{}
+	if *title != "" {
+		form.Set("title", *title)
+	}
+	if set["desc"] || set["desc-file"] || set["desc-stdin"] {
+		form.Set("description", readBody(*desc, *descFile, *descStdin))
+	}
+	if *addLabels != "" {
+		form.Set("add_labels", strings.Join(splitCSV(*addLabels), ","))
+	}
+	if *removeLabels != "" {
+		form.Set("remove_labels", strings.Join(splitCSV(*removeLabels), ","))
+	}
+	issueForm(c, encoded, form, set, map[string]string{
+		"assignees": *assignees, "labels": *labels, "milestone": *milestone,
+		"due": *due, "weight": *weight, "confidential": *confidential,
+	})
+	if len(form) == 0 {
+		die("issue-update: nothing to change")
+	}
+
+	data, err := c.put("/projects/"+encoded+"/issues/"+issueIID, form)
+	if err != nil {
+		die("issue-update: %v", err)
+	}
+	printIssueResult("updated", data)
+}
+
+// cmdIssueState closes or reopens an issue, optionally leaving a comment
+// explaining why.
+//
+//	<host> issue-close <project> <iid> [--comment "..."]
+//	<host> issue-reopen <project> <iid> [--comment "..."]
+func cmdIssueState(c *apiClient, args []string, event string) {
+	if len(args) < 2 {
+		die("Usage: issue-%s <project> <iid> [--comment \"...\"]", event)
+	}
+	encoded := url.PathEscape(args[0])
+	issueIID := args[1]
+	fs := flag.NewFlagSet("issue-"+event, flag.ExitOnError)
+	comment := fs.String("comment", "", "note to add before changing state")
+	_ = fs.Parse(args[2:])
+
+	endpoint := "/projects/" + encoded + "/issues/" + issueIID
+	if *comment != "" {
+		if _, err := c.post(endpoint+"/notes", url.Values{"body": {*comment}}); err != nil {
+			die("issue-%s: comment: %v", event, err)
+		}
+	}
+	data, err := c.put(endpoint, url.Values{"state_event": {event}})
+	if err != nil {
+		die("issue-%s: %v", event, err)
+	}
+	verb := "closed"
+	if event == "reopen" {
+		verb = "reopened"
+	}
+	printIssueResult(verb, data)
+}
+
+// cmdIssueNote adds a comment to an issue.
+//
+//	<host> issue-note <project> <iid> --body "..." | --body-file path | --body-stdin [--internal]
+func cmdIssueNote(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: issue-note <project> <iid> --body \"...\" [--internal]")
+	}
+	encoded := url.PathEscape(args[0])
+	issueIID := args[1]
+	fs := flag.NewFlagSet("issue-note", flag.ExitOnError)
+	body := fs.String("body", "", "note text")
+	bodyFile := fs.String("body-file", "", "read note from file")
+	bodyStdin := fs.Bool("body-stdin", false, "read note from stdin")
+	internal := fs.Bool("internal", false, "internal note (visible to project members only)")
+	_ = fs.Parse(args[2:])
+
+	text := readBody(*body, *bodyFile, *bodyStdin)
+	if strings.TrimSpace(text) == "" {
+		die("issue-note: body is empty (use --body, --body-file, or --body-stdin)")
+	}
+	form := url.Values{"body": {text}}
+	if *internal {
+		form.Set("internal", "true")
+	}
+	data, err := c.post("/projects/"+encoded+"/issues/"+issueIID+"/notes", form)
+	if err != nil {
+		die("issue-note: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("note %s added to #%s\n", jsonStr(m, "id"), issueIID)
+}
+
+// cmdIssueLink links two issues. The target is an IID in the same project
+// or "group/project#IID" elsewhere.
+//
+//	<host> issue-link <project> <iid> <target> [--type relates_to|blocks|is_blocked_by]
+func cmdIssueLink(c *apiClient, args []string) {
+	if len(args) < 3 {
+		die("Usage: issue-link <project> <iid> <[project#]iid> [--type relates_to|blocks|is_blocked_by]")
+	}
+	encoded := url.PathEscape(args[0])
+	issueIID := args[1]
+	targetProject, targetIID := args[0], strings.TrimPrefix(args[2], "#")
+	if p, iid, ok := strings.Cut(args[2], "#"); ok && p != "" {
+		targetProject, targetIID = p, iid
+	}
+	fs := flag.NewFlagSet("issue-link", flag.ExitOnError)
+	linkType := fs.String("type", "relates_to", "relates_to, blocks, or is_blocked_by")
+	_ = fs.Parse(args[3:])
+
+	data, err := c.post("/projects/"+encoded+"/issues/"+issueIID+"/links", url.Values{
+		"target_project_id": {targetProject},
+		"target_issue_iid":  {targetIID},
+		"link_type":         {*linkType},
+	})
+	if err != nil {
+		var ae *apiError
+		if errors.As(err, &ae) && ae.StatusCode == 409 {
+			die("issue-link: #%s is already linked to %s", issueIID, args[2])
+		}
+		die("issue-link: %v", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	target := "#" + targetIID
+	if targetProject != args[0] {
+		target = targetProject + target
+	}
+	fmt.Printf("#%s %s %s\n", issueIID, strings.ReplaceAll(strOr(jsonStr(m, "link_type"), *linkType), "_", " "), target)
+}
+
+// ── Milestones ──────────────────────────────────────────────
+
+// projectOrGroupBase resolves a project or group reference to its API
+// prefix ("/projects/x" or "/groups/x"), trying the project first.
+func projectOrGroupBase(c *apiClient, ref string) (string, error) {
+	encoded := url.PathEscape(ref)
+	_, err := c.get("/projects/"+encoded, nil)
+	if err == nil {
+		return "/projects/" + encoded, nil
+	}
+	var ae *apiError
+	if !errors.As(err, &ae) || ae.StatusCode != 404 {
+		return "", err
+	}
+	if _, err := c.get("/groups/"+encoded, nil); err != nil {
+		return "", fmt.Errorf("%s is neither a project nor a group: %w", ref, err)
+	}
+	return "/groups/" + encoded, nil
+}
+
+// cmdMilestones lists the milestones of a project or group.
+//
+//	<host> milestones <project|group> [state: active|closed|all]
+func cmdMilestones(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: milestones <project|group> [active|closed|all]")
+	}
+	base, err := projectOrGroupBase(c, args[0])
+	if err != nil {
+		die("%s", err)
+	}
+	params := url.Values{"per_page": {"50"}}
+	if len(args) > 1 && args[1] != "all" {
+		params.Set("state", args[1])
+	} else if len(args) == 1 {
+		params.Set("state", "active")
+	}
+	data, err := c.get(base+"/milestones", params)
+	if err != nil {
+		die("%s", err)
+	}
+	var ms []any
+	json.Unmarshal(data, &ms)
+	for _, m := range ms {
+		mm := asMap(m)
+		fmt.Printf("[%s] %s  (%s)\n", jsonStr(mm, "id"), jsonStr(mm, "title"), jsonStr(mm, "state"))
+		fmt.Printf("  %s -> %s  %s\n", strOr(jsonStr(mm, "start_date"), "?"), strOr(jsonStr(mm, "due_date"), "?"),
+			daysRemaining(jsonStr(mm, "due_date"), time.Now()))
+		fmt.Printf("  %s\n\n", jsonStr(mm, "web_url"))
+	}
+}
+
+// daysRemaining describes a YYYY-MM-DD due date relative to now.
+func daysRemaining(due string, now time.Time) string {
+	d, err := time.Parse("2006-01-02", due)
+	if err != nil {
+		return "no due date"
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	n := int(d.Sub(today).Hours() / 24)
+	switch {
+	case n > 0:
+		return fmt.Sprintf("%d day(s) remaining", n)
+	case n == 0:
+		return "due today"
+	default:
+		return fmt.Sprintf("overdue by %d day(s)", -n)
+	}
+}
+
+type burnupPoint struct {
+	date                      time.Time
+	scope, closed             int
+	scopeWeight, closedWeight int
+}
+
+// burnup rebuilds cumulative scope and completion from issue created_at
+// and closed_at. Scope counts issues that existed on each date, which is
+// an approximation: the API has no history of when an issue joined the
+// milestone.
+func burnup(issues []map[string]any, start, end time.Time) []burnupPoint {
+	step := 24 * time.Hour
+	if end.Sub(start) > 28*24*time.Hour {
+		step = 7 * 24 * time.Hour
+	}
+	var points []burnupPoint
+	for d := start; ; d = d.Add(step) {
+		if d.After(end) {
+			d = end
+		}
+		cutoff := d.Add(24 * time.Hour) // inclusive of the whole day
+		p := burnupPoint{date: d}
+		for _, is := range issues {
+			w := int(jsonNum(is, "weight"))
+			if t, err := time.Parse(time.RFC3339, jsonStr(is, "created_at")); err == nil && !t.Before(cutoff) {
+				continue
+			}
+			p.scope++
+			p.scopeWeight += w
+			if t, err := time.Parse(time.RFC3339, jsonStr(is, "closed_at")); err == nil && t.Before(cutoff) {
+				p.closed++
+				p.closedWeight += w
+			}
+		}
+		points = append(points, p)
+		if !d.Before(end) {
+			return points
+		}
+	}
+}
+
+// assigneeNames returns an issue's or MR's assignees as @usernames, or
+// "(unassigned)".
+func assigneeNames(m map[string]any) []string {
+	var names []string
+	for _, a := range jsonArr(m, "assignees") {
+		names = append(names, "@"+jsonStr(asMap(a), "username"))
+	}
+	if len(names) == 0 {
+		names = []string{"(unassigned)"}
+	}
+	return names
+}
+
+func progressBar(done, total, width int) string {
+	if total == 0 {
+		return strings.Repeat(".", width)
+	}
+	n := done * width / total
+	return strings.Repeat("#", n) + strings.Repeat(".", width-n)
+}
+
+// cmdMilestoneReport summarizes a milestone's issues and MRs: state and
+// assignee breakdowns, weight totals, percent complete, days remaining,
+// and a burnup series.
+//
+//	<host> milestone-report <project|group> <milestone-id|title> [--format text|md]
+//
+// Percent complete is by weight when any issue is weighted, otherwise by
+// issue count.
+func cmdMilestoneReport(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: milestone-report <project|group> <milestone-id|title> [--format text|md]")
+	}
+	fs := flag.NewFlagSet("milestone-report", flag.ExitOnError)
+	format := fs.String("format", "text", "text or md")
+	_ = fs.Parse(args[2:])
+	md := *format == "md"
+	if !md && *format != "text" {
+		die("milestone-report: --format must be text or md")
+	}
+
+	base, err := projectOrGroupBase(c, args[0])
+	if err != nil {
+		die("%s", err)
+	}
+	var ms map[string]any
+	if _, err := strconv.Atoi(args[1]); err == nil {
+		data, err := c.get(base+"/milestones/"+args[1], nil)
+		if err != nil {
+			die("%s", err)
+		}
+		json.Unmarshal(data, &ms)
+	} else if ms, err = findMilestone(c, base, args[1]); err != nil {
+		die("milestone-report: %s: %v", args[0], err)
+	}
+	id := jsonStr(ms, "id")
+	// A title can resolve to an ancestor group's milestone, whose issues
+	// and MRs are only listed under that group.
+	if gid := jsonStr(ms, "group_id"); gid != "" && strings.HasPrefix(base, "/projects/") {
+		base = "/groups/" + gid
+	}
+	rawIssues, err := c.getAll(base+"/milestones/"+id+"/issues", nil)
+	if err != nil {
+		die("issues: %v", err)
+	}
+	rawMRs, err := c.getAll(base+"/milestones/"+id+"/merge_requests", nil)
+	if err != nil {
+		die("merge requests: %v", err)
+	}
+	var issues, mrs []map[string]any
+	for _, it := range rawIssues {
+		issues = append(issues, asMap(it))
+	}
+	for _, it := range rawMRs {
+		mrs = append(mrs, asMap(it))
+	}
+
+	type tally struct{ open, closed, openWeight, closedWeight int }
+	var total tally
+	byAssignee := map[string]*tally{}
+	for _, is := range issues {
+		w := int(jsonNum(is, "weight"))
+		targets := []*tally{&total}
+		for _, n := range assigneeNames(is) {
+			if byAssignee[n] == nil {
+				byAssignee[n] = &tally{}
+			}
+			targets = append(targets, byAssignee[n])
+		}
+		closed := jsonStr(is, "state") == "closed"
+		for _, t := range targets {
+			if closed {
+				t.closed++
+				t.closedWeight += w
+			} else {
+				t.open++
+				t.openWeight += w
+			}
+		}
+	}
+	weighted := total.openWeight+total.closedWeight > 0
+	pct := 0
+	switch {
+	case weighted:
+		pct = total.closedWeight * 100 / (total.openWeight + total.closedWeight)
+	case len(issues) > 0:
+		pct = total.closed * 100 / len(issues)
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	h := func(level int, title string) {
+		if md {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s %s\n\n", strings.Repeat("#", level), title)
+		} else if level == 1 {
+			fmt.Fprintf(&b, "%s\n%s\n", title, strings.Repeat("=", len(title)))
+		} else {
+			fmt.Fprintf(&b, "\n%s\n", title)
+		}
+	}
+	h(1, "Milestone: "+jsonStr(ms, "title"))
+	fmt.Fprintf(&b, "State: %s  Dates: %s -> %s  (%s)\n", jsonStr(ms, "state"),
+		strOr(jsonStr(ms, "start_date"), "?"), strOr(jsonStr(ms, "due_date"), "?"), daysRemaining(jsonStr(ms, "due_date"), now))
+	if md {
+		b.WriteString("\n")
+	}
+	basis := "issues"
+	if weighted {
+		basis = "weight"
+	}
+	fmt.Fprintf(&b, "Progress: %d%% by %s  [%s]\n", pct, basis, progressBar(pct, 100, 30))
+	if md {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Issues: %d open, %d closed  Weight: %d open, %d closed\n",
+		total.open, total.closed, total.openWeight, total.closedWeight)
+
+	h(2, "Issues by assignee")
+	names := make([]string, 0, len(byAssignee))
+	for n := range byAssignee {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if md {
+		b.WriteString("| Assignee | Open | Closed | Open weight | Closed weight |\n|---|---:|---:|---:|---:|\n")
+	} else {
+		fmt.Fprintf(&b, "  %-24s %6s %6s %8s %8s\n", "Assignee", "Open", "Closed", "W open", "W done")
+	}
+	for _, n := range names {
+		t := byAssignee[n]
+		if md {
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %d |\n", n, t.open, t.closed, t.openWeight, t.closedWeight)
+		} else {
+			fmt.Fprintf(&b, "  %-24s %6d %6d %8d %8d\n", n, t.open, t.closed, t.openWeight, t.closedWeight)
+		}
+	}
+
+	for _, state := range []string{"opened", "closed"} {
+		title := "Open issues"
+		if state == "closed" {
+			title = "Closed issues"
+		}
+		h(2, title)
+		for _, is := range issues {
+			if jsonStr(is, "state") != state {
+				continue
+			}
+			extra := ""
+			if w := jsonStr(is, "weight"); w != "" {
+				extra = "  w" + w
+			}
+			extra += "  " + strings.Join(assigneeNames(is), ",")
+			if md {
+				fmt.Fprintf(&b, "- [#%s](%s) %s%s\n", jsonStr(is, "iid"), jsonStr(is, "web_url"), jsonStr(is, "title"), extra)
+			} else {
+				fmt.Fprintf(&b, "  #%-6s %s%s\n", jsonStr(is, "iid"), jsonStr(is, "title"), extra)
+			}
+		}
+	}
+
+	h(2, "Merge requests")
+	mrCount := map[string]int{}
+	mrByAssignee := map[string]map[string]int{}
+	for _, mr := range mrs {
+		state := jsonStr(mr, "state")
+		mrCount[state]++
+		for _, n := range assigneeNames(mr) {
+			if mrByAssignee[n] == nil {
+				mrByAssignee[n] = map[string]int{}
+			}
+			mrByAssignee[n][state]++
+		}
+	}
+	fmt.Fprintf(&b, "%d merged, %d open, %d closed\n", mrCount["merged"], mrCount["opened"], mrCount["closed"])
+	if len(mrs) > 0 {
+		names = names[:0]
+		for n := range mrByAssignee {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if md {
+			b.WriteString("\n| Assignee | Open | Merged | Closed |\n|---|---:|---:|---:|\n")
+		} else {
+			fmt.Fprintf(&b, "\n  %-24s %6s %6s %6s\n", "Assignee", "Open", "Merged", "Closed")
+		}
+		for _, n := range names {
+			t := mrByAssignee[n]
+			if md {
+				fmt.Fprintf(&b, "| %s | %d | %d | %d |\n", n, t["opened"], t["merged"], t["closed"])
+			} else {
+				fmt.Fprintf(&b, "  %-24s %6d %6d %6d\n", n, t["opened"], t["merged"], t["closed"])
+			}
+		}
+	}
+	for _, st := range []struct{ state, title string }{{"opened", "Open MRs"}, {"merged", "Merged MRs"}, {"closed", "Closed MRs"}} {
+		if mrCount[st.state] == 0 {
+			continue
+		}
+		h(3, st.title)
+		for _, mr := range mrs {
+			if jsonStr(mr, "state") != st.state {
+				continue
+			}
+			who := strings.Join(assigneeNames(mr), ",")
+			if md {
+				fmt.Fprintf(&b, "- [!%s](%s) %s  %s\n", jsonStr(mr, "iid"), jsonStr(mr, "web_url"), jsonStr(mr, "title"), who)
+			} else {
+				fmt.Fprintf(&b, "  !%-6s %s  %s\n", jsonStr(mr, "iid"), jsonStr(mr, "title"), who)
+			}
+		}
+	}
+
+	start, err := time.Parse("2006-01-02", jsonStr(ms, "start_date"))
+	if err != nil {
+		for _, is := range issues {
+			if t, err := time.Parse(time.RFC3339, jsonStr(is, "created_at")); err == nil && (start.IsZero() || t.Before(start)) {
+				start = t.UTC().Truncate(24 * time.Hour)
+			}
+		}
+	}
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if due, err := time.Parse("2006-01-02", jsonStr(ms, "due_date")); err == nil && due.Before(end) {
+		end = due
+	}
+	if !start.IsZero() && !start.After(end) {
+		h(2, "Burnup ("+basis+" closed / scope)")
+		if md {
+			b.WriteString("| Date | Closed | Scope | Closed weight | Scope weight |\n|---|---:|---:|---:|---:|\n")
+		}
+		for _, p := range burnup(issues, start, end) {
+			if md {
+				fmt.Fprintf(&b, "| %s | %d | %d | %d | %d |\n", p.date.Format("2006-01-02"), p.closed, p.scope, p.closedWeight, p.scopeWeight)
+				continue
+			}
+			done, scope := p.closed, p.scope
+			if weighted {
+				done, scope = p.closedWeight, p.scopeWeight
+			}
+			fmt.Fprintf(&b, "  %s  %3d/%-3d [%s]\n", p.date.Format("2006-01-02"), done, scope, progressBar(done, scope, 30))
+		}
+	}
+	fmt.Print(b.String())
+}
+
+// ── CODEOWNERS ──────────────────────────────────────────────
+
+// codeownersPaths are checked in GitLab's order; the first file found wins.
+var codeownersPaths = []string{"CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+type codeownersSection struct {
+	name          string
+	optional      bool
+	approvals     int
+	defaultOwners []string
+}
+
+type codeownersRule struct {
+	pattern string
+	re      *regexp.Regexp
+	owners  []string
+	exclude bool
+	section *codeownersSection
+	line    int
+}
+
+type codeowners struct {
+	sections []*codeownersSection
+	rules    []codeownersRule
+}
+
+// sectionHeaderRe matches "[Name]", "^[Optional]", "[Name][2]" and
+// "[Name] @default @owners".
+var sectionHeaderRe = regexp.MustCompile(`^(\^)?\[([^\]]+)\](?:\[(\d+)\])?\s*(.*)$`)
+
+// parseCodeowners parses GitLab CODEOWNERS syntax: comments, sections
+// with optional "^" prefix, approval counts and default owners, "!"
+// exclusion patterns, and "\ "/"\#" escapes in paths. Sections with the
+// same name (case-insensitive) are merged. Problems are returned as
+// warnings rather than failing the whole file, as GitLab does.
+func parseCodeowners(src string) (*codeowners, []string) {
+	co := &codeowners{}
+	var warnings []string
+	byName := map[string]*codeownersSection{}
+	section := func(name string) *codeownersSection {
+		key := strings.ToLower(name)
+		if s, ok := byName[key]; ok {
+			return s
+		}
+		s := &codeownersSection{name: name, approvals: 1}
+		byName[key] = s
+		co.sections = append(co.sections, s)
+		return s
+	}
+	current := section("Codeowners")
+
+	for n, raw := range strings.Split(src, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := sectionHeaderRe.FindStringSubmatch(line); m != nil && strings.HasPrefix(strings.TrimPrefix(line, "^"), "[") {
+			current = section(strings.TrimSpace(m[2]))
+			current.optional = m[1] == "^"
+			if m[3] != "" {
+				current.approvals, _ = strconv.Atoi(m[3])
+			}
+			if owners := strings.Fields(m[4]); len(owners) > 0 {
+				current.defaultOwners = owners
+			}
+			continue
+		}
+		fields := splitEscaped(line)
+		pattern := fields[0]
+		rule := codeownersRule{owners: fields[1:], section: current, line: n + 1}
+		if strings.HasPrefix(pattern, "!") {
+			rule.exclude = true
+			pattern = pattern[1:]
+		}
+		rule.pattern = pattern
+		re, err := codeownersRegexp(pattern)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: %s: %v", n+1, pattern, err))
+			continue
+		}
+		rule.re = re
+		for _, o := range rule.owners {
+			if !strings.HasPrefix(o, "@") && !strings.Contains(o, "@") {
+				warnings = append(warnings, fmt.Sprintf("line %d: %q is not a user, group, role or email", n+1, o))
+			}
+		}
+		co.rules = append(co.rules, rule)
+	}
+	return co, warnings
+}
+
+// splitEscaped splits on unescaped whitespace and resolves "\ " and "\#".
+func splitEscaped(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case ch == '\\' && i+1 < len(line) && (line[i+1] == ' ' || line[i+1] == '#'):
+			cur.WriteByte(line[i+1])
+			i++
+		case ch == ' ' || ch == '\t':
+			if cur.Len() > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteByte(ch)
+		}
+	}
+	if cur.Len() > 0 {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+// codeownersRegexp compiles a CODEOWNERS path pattern:
+//
+//   - a leading "/" anchors at the repository root; otherwise the pattern
+//     matches at any depth (GitLab treats "README.md" as "/**/README.md")
+//   - a trailing "/" matches everything inside that directory
+//   - "*" and "?" stop at "/", "**" crosses directories, "[...]" is a
+//     character class ("[!...]" negated)
+//
+// Otherwise the whole path must match, as with fnmatch(FNM_PATHNAME):
+// "/docs" is only a file named docs, and "/docs/*" only its direct files.
+func codeownersRegexp(pattern string) (*regexp.Regexp, error) {
+	p := pattern
+	anchored := strings.HasPrefix(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	if p == "" {
+		if anchored {
+			return regexp.Compile(`^.*$`)
+		}
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored && !strings.HasPrefix(p, "**") {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		ch := p[i]
+		switch ch {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				i++
+				if i+1 < len(p) && p[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(p) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(p[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	if dirOnly {
+		b.WriteString("/.*")
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// owners returns, per section, the rule that owns path: the last matching
+// rule in that section, unless an exclusion pattern in the section
+// matches. Rules without owners fall back to the section's default
+// owners.
+func (co *codeowners) owners(path string) map[*codeownersSection]*codeownersRule {
+	out := map[*codeownersSection]*codeownersRule{}
+	excluded := map[*codeownersSection]bool{}
+	for i := range co.rules {
+		r := &co.rules[i]
+		if !r.re.MatchString(path) {
+			continue
+		}
+		if r.exclude {
+			excluded[r.section] = true
+			continue
+		}
+		out[r.section] = r
+	}
+	for s := range excluded {
+		delete(out, s)
+	}
+	return out
+}
+
+func (r *codeownersRule) effectiveOwners() []string {
+	if len(r.owners) > 0 {
+		return r.owners
+	}
+	return r.section.defaultOwners
+}
+
+// fetchCodeowners returns the first CODEOWNERS file found at ref.
+func fetchCodeowners(c *apiClient, encoded, ref string) (path, content string, err error) {
+	for _, p := range codeownersPaths {
+		data, err := c.getRaw("/projects/"+encoded+"/repository/files/"+url.PathEscape(p)+"/raw", url.Values{"ref": {ref}})
+		if err == nil {
+			return p, string(data), nil
+		}
+		var ae *apiError
+		if !errors.As(err, &ae) || ae.StatusCode != 404 {
+			return "", "", err
+		}
+	}
+	return "", "", fmt.Errorf("no CODEOWNERS file at %s (looked in %s)", ref, strings.Join(codeownersPaths, ", "))
+}
+
+// groupMembers lists usernames in a group including inherited members,
+// cached per run. Failures yield an empty set.
+func groupMembers(c *apiClient, cache map[string]map[string]bool, group string) map[string]bool {
+	if m, ok := cache[group]; ok {
+		return m
+	}
+	m := map[string]bool{}
+	if items, err := c.getAll("/groups/"+url.PathEscape(group)+"/members/all", nil); err == nil {
+		for _, it := range items {
+			m[jsonStr(asMap(it), "username")] = true
+		}
+	}
+	cache[group] = m
+	return m
+}
+
+// ownerIncludes reports whether a CODEOWNERS owner covers username: the
+// user themselves or a member of the owning group. Roles ("@@maintainer")
+// and email owners cannot be matched to a username.
+func ownerIncludes(c *apiClient, cache map[string]map[string]bool, owner, username string) bool {
+	switch {
+	case strings.HasPrefix(owner, "@@") || !strings.HasPrefix(owner, "@"):
+		return false
+	case strings.TrimPrefix(owner, "@") == username:
+		return true
+	default:
+		return groupMembers(c, cache, strings.TrimPrefix(owner, "@"))[username]
+	}
+}
+
+// cmdMROwners reports which CODEOWNERS sections and owners must review
+// each file an MR changes, and which required sections still lack
+// approval.
+//
+//	<host> mr-owners <project> <iid>
+//
+// CODEOWNERS is read from the MR's target branch. Approval progress comes
+// from the MR's approval state (code owner rules) when the tier exposes
+// it, otherwise from approvers matched against section owners.
+func cmdMROwners(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: mr-owners <project> <iid>")
+	}
+	encoded := url.PathEscape(args[0])
+	mrIID := args[1]
+	data, err := c.get("/projects/"+encoded+"/merge_requests/"+mrIID, nil)
+	if err != nil {
+		die("%s", err)
+	}
+	var mr map[string]any
+	json.Unmarshal(data, &mr)
+	target := jsonStr(mr, "target_branch")
+
+	path, content, err := fetchCodeowners(c, encoded, target)
+	if err != nil {
+		die("mr-owners: %v", err)
+	}
+	co, warnings := parseCodeowners(content)
+	diffs, err := mrDiffs(c, encoded, mrIID)
+	if err != nil {
+		die("%s", err)
+	}
+
+	fmt.Printf("!%s %s\n", mrIID, jsonStr(mr, "title"))
+	used := map[*codeownersSection]bool{}
+	for _, r := range co.rules {
+		used[r.section] = true
+	}
+	fmt.Printf("CODEOWNERS: %s @ %s (%d rule(s), %d section(s))\n", path, target, len(co.rules), len(used))
+	for _, w := range warnings {
+		fmt.Printf("  warning: %s\n", w)
+	}
+
+	sectionOwners := map[*codeownersSection]map[string]bool{}
+	suggested := map[string]bool{}
+	fmt.Printf("\nFiles (%d):\n", len(diffs))
+	for _, d := range diffs {
+		paths := []string{jsonStr(d, "new_path")}
+		if old := jsonStr(d, "old_path"); old != "" && old != paths[0] {
+			paths = append(paths, old)
+		}
+		fmt.Printf("  %s\n", strings.Join(paths, " <- "))
+		matched := map[*codeownersSection]*codeownersRule{}
+		for _, p := range paths {
+			for s, r := range co.owners(p) {
+				matched[s] = r
+			}
+		}
+		if len(matched) == 0 {
+			fmt.Printf("    (no owners)\n")
+			continue
+		}
+		for _, s := range co.sections {
+			r, ok := matched[s]
+			if !ok {
+				continue
+			}
+			if sectionOwners[s] == nil {
+				sectionOwners[s] = map[string]bool{}
+			}
+			owners := r.effectiveOwners()
+			for _, o := range owners {
+				sectionOwners[s][o] = true
+				suggested[o] = true
+			}
+			fmt.Printf("    [%s] %-28s %s  (line %d)\n", s.name, r.pattern, strOr(strings.Join(owners, " "), "(no owners)"), r.line)
+		}
+	}
+
+	// Approval progress per section.
+	approvedSections := map[string]bool{}
+	haveState := false
+	if data, err := c.get("/projects/"+encoded+"/merge_requests/"+mrIID+"/approval_state", nil); err == nil {
+		var st map[string]any
+		json.Unmarshal(data, &st)
+		pending := map[string]bool{}
+		for _, r := range jsonArr(st, "rules") {
+			rm := asMap(r)
+			if jsonStr(rm, "rule_type") != "code_owner" {
+				continue
+			}
+			haveState = true
+			sec := strings.ToLower(strOr(jsonStr(rm, "section"), "codeowners"))
+			if rm["approved"] != true {
+				pending[sec] = true
+			} else if !pending[sec] {
+				approvedSections[sec] = true
+			}
+		}
+		for sec := range pending {
+			delete(approvedSections, sec)
+		}
+	}
+	var approvers []string
+	if !haveState {
+		if data, err := c.get("/projects/"+encoded+"/merge_requests/"+mrIID+"/approvals", nil); err == nil {
+			var ap map[string]any
+			json.Unmarshal(data, &ap)
+			for _, a := range jsonArr(ap, "approved_by") {
+				approvers = append(approvers, jsonStr(jsonMap(asMap(a), "user"), "username"))
+			}
+		}
+	}
+
+	fmt.Printf("\nSections:\n")
+	memberCache := map[string]map[string]bool{}
+	missing := 0
+	for _, s := range co.sections {
+		if sectionOwners[s] == nil {
+			continue
+		}
+		kind := fmt.Sprintf("required, %d approval(s)", s.approvals)
+		if s.optional {
+			kind = "optional"
+		}
+		var status string
+		if haveState {
+			status = "approved"
+			if !approvedSections[strings.ToLower(s.name)] {
+				status = "awaiting approval"
+			}
+		} else {
+			var by []string
+			for _, u := range approvers {
+				for o := range sectionOwners[s] {
+					if ownerIncludes(c, memberCache, o, u) {
+						by = append(by, "@"+u)
+						break
+					}
+				}
+			}
+			status = fmt.Sprintf("%d/%d approved", len(by), s.approvals)
+			if len(by) > 0 {
+				status += " by " + strings.Join(by, ", ")
+			}
+			if len(by) >= s.approvals {
+				status = "approved (" + status + ")"
+			} else {
+				status = "awaiting approval (" + status + ")"
+			}
+		}
+		if !s.optional && strings.HasPrefix(status, "awaiting") {
+			missing++
+			status += "  <- MISSING"
+		}
+		fmt.Printf("  %-20s %-26s %s\n", s.name, kind, status)
+	}
+	if len(sectionOwners) == 0 {
+		fmt.Printf("  (no section owns any changed file)\n")
+	}
+
+	names := make([]string, 0, len(suggested))
+	for o := range suggested {
+		names = append(names, o)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		fmt.Printf("\nSuggested reviewers: %s\n", strings.Join(names, ", "))
+	}
+	if missing > 0 {
+		fmt.Printf("%d required section(s) still need code owner approval\n", missing)
+	}
+}
+
+// ── GraphQL ─────────────────────────────────────────────────
+
+// cmdGraphQL runs a query file against /api/graphql and prints the data.
+//
+//	<host> graphql <query-file|-> [-v name=string | -v name:=json ...] [--paginate path.to.connection]
+//
+// -v name=value always sends a string; -v name:=value sends value as a JSON
+// literal (numbers, booleans, null, lists, objects). --paginate follows the cursor on
+// the named connection (the query must take $after and select
+// pageInfo { hasNextPage endCursor }) and prints all nodes. Exits 1 if the
+// response carries errors.
+func cmdGraphQL(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: graphql <query-file|-> [-v name=string | -v name:=json ...] [--paginate path.to.connection]")
+	}
+	fs := flag.NewFlagSet("graphql", flag.ExitOnError)
+	var vars stringList
+	fs.Var(&vars, "v", "variable as name=string or name:=json (repeatable)")
+	paginate := fs.String("paginate", "", "dotted path to a connection to page through")
+	_ = fs.Parse(args[1:])
+
+	var query string
+	if args[0] == "-" {
+		query = readBody("", "", true)
+	} else {
+		query = readBody("", args[0], false)
+	}
+	if strings.TrimSpace(query) == "" {
+		die("graphql: empty query")
+	}
+	v, err := parseGraphQLVars(vars)
+	if err != nil {
+		die("%s", err)
+	}
+
+	if *paginate != "" {
+		nodes, err := c.graphqlAll(query, v, strings.Split(*paginate, ".")...)
+		if err != nil {
+			die("graphql: %v", err)
+		}
+		printJSON(nodes)
+		return
+	}
+	data, err := c.graphql(query, v)
+	if data != nil {
+		printJSON(data)
+	}
+	if err != nil {
+		die("graphql: %v", err)
+	}
+}
+
+// parseGraphQLVars turns -v arguments into query variables. "name=value"
+// is always a string, so "iid=42" matches a String! argument;
+// "name:=value" must be a JSON literal.
+func parseGraphQLVars(pairs []string) (map[string]any, error) {
+	v := map[string]any{}
+	for _, pair := range pairs {
+		name, val, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || name == ":" {
+			return nil, fmt.Errorf("variable %q must be name=string or name:=json", pair)
+		}
+		if raw, isJSON := strings.CutSuffix(name, ":"); isJSON {
+			var parsed any
+			if err := json.Unmarshal([]byte(val), &parsed); err != nil {
+				return nil, fmt.Errorf("variable %s: invalid JSON %q: %v", raw, val, err)
+			}
+			v[raw] = parsed
+			continue
+		}
+		v[name] = val
+	}
+	return v, nil
+}
+
+// ── CI/CD variables and runners ─────────────────────────────
+
+// cmdVariables lists CI/CD variable keys and their settings. Values are
+// never printed unless --reveal is given.
+//
+//	<host> variables <project|group> [--reveal] [--env scope]
+func cmdVariables(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: variables <project|group> [--reveal] [--env scope]")
+	}
+	fs := flag.NewFlagSet("variables", flag.ExitOnError)
+	reveal := fs.Bool("reveal", false, "print values")
+	env := fs.String("env", "", "only variables with this environment scope")
+	_ = fs.Parse(args[1:])
+
+	base, err := projectOrGroupBase(c, args[0])
+	if err != nil {
+		die("%s", err)
+	}
+	items, err := c.getAll(base+"/variables", nil)
+	if err != nil {
+		die("%s", err)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return jsonStr(asMap(items[i]), "key") < jsonStr(asMap(items[j]), "key")
+	})
+	shown := 0
+	for _, it := range items {
+		v := asMap(it)
+		scope := strOr(jsonStr(v, "environment_scope"), "*")
+		if *env != "" && scope != *env {
+			continue
+		}
+		shown++
+		var flags []string
+		for _, f := range []string{"protected", "masked", "hidden", "raw"} {
+			if v[f] == true {
+				flags = append(flags, f)
+			}
+		}
+		if jsonStr(v, "variable_type") == "file" {
+			flags = append(flags, "file")
+		}
+		fmt.Printf("%s  env=%s", jsonStr(v, "key"), scope)
+		if len(flags) > 0 {
+			fmt.Printf("  [%s]", strings.Join(flags, ","))
+		}
+		fmt.Println()
+		if d := jsonStr(v, "description"); d != "" {
+			fmt.Printf("  %s\n", d)
+		}
+		if *reveal {
+			if v["value"] == nil {
+				fmt.Printf("  value: (hidden by GitLab)\n")
+			} else {
+				fmt.Printf("  value: %s\n", jsonStr(v, "value"))
+			}
+		}
+	}
+	fmt.Printf("\n%d variable(s) in %s", shown, args[0])
+	if !*reveal {
+		fmt.Printf(" (values hidden; --reveal to show)")
+	}
+	fmt.Println()
+}
+
+// cmdVariableSet creates or updates a CI/CD variable. The value comes
+// from the argument, a file, or stdin and is never echoed.
+//
+//	<host> variable-set <project|group> <key> [value | --value-file f | --value-stdin]
+//	                    [--env scope] [--protected] [--masked] [--raw] [--file] [--description "..."]
+//
+// Setting flags that are not passed keep their current values on update.
+func cmdVariableSet(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: variable-set <project|group> <key> [value | --value-file f | --value-stdin] [flags]")
+	}
+	key := args[1]
+	rest := args[2:]
+	literal := ""
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		literal, rest = rest[0], rest[1:]
+	}
+	fs := flag.NewFlagSet("variable-set", flag.ExitOnError)
+	valueFile := fs.String("value-file", "", "read the value from a file")
+	valueStdin := fs.Bool("value-stdin", false, "read the value from stdin")
+	env := fs.String("env", "", "environment scope (default *)")
+	protected := fs.Bool("protected", false, "only expose to protected branches and tags")
+	masked := fs.Bool("masked", false, "mask the value in job logs")
+	raw := fs.Bool("raw", false, "do not expand $VARIABLES in the value")
+	file := fs.Bool("file", false, "file-type variable")
+	description := fs.String("description", "", "variable description")
+	_ = fs.Parse(rest)
+	set := flagsSet(fs)
+
+	value := readBody(literal, *valueFile, *valueStdin)
+	if *valueFile != "" || *valueStdin {
+		value = strings.TrimSuffix(value, "\n")
+	}
+	if value == "" && literal == "" && *valueFile == "" && !*valueStdin {
+		die("variable-set: no value (pass it, or use --value-file / --value-stdin)")
+	}
+	base, err := projectOrGroupBase(c, args[0])
+	if err != nil {
+		die("%s", err)
+	}
+
+	form := url.Values{"value": {value}}
+	for name, on := range map[string]bool{"protected": *protected, "masked": *masked, "raw": *raw} {
+		if set[name] {
+			form.Set(name, strconv.FormatBool(on))
+		}
+	}
+	if set["file"] {
+		form.Set("variable_type", map[bool]string{true: "file", false: "env_var"}[*file])
+	}
+	if set["description"] {
+		form.Set("description", *description)
+	}
+	endpoint := base + "/variables/" + url.PathEscape(key)
+	if *env != "" {
+		form.Set("environment_scope", *env)
+		endpoint += "?" + url.Values{"filter[environment_scope]": {*env}}.Encode()
+	}
+
+	verb := "updated"
+	_, err = c.put(endpoint, form)
+	var ae *apiError
+	if errors.As(err, &ae) && ae.StatusCode == 404 {
+		verb = "created"
+		form.Set("key", key)
+		_, err = c.post(base+"/variables", form)
+	}
+	if err != nil {
+		if errors.As(err, &ae) && ae.StatusCode == 400 && *masked {
+			die("variable-set: %v\n(masked values must be at least 8 characters from the Base64 alphabet, on a single line)", err)
+		}
+		die("variable-set: %v", err)
+	}
+	fmt.Printf("%s %s in %s (env=%s)\n", key, verb, args[0], strOr(*env, "*"))
+}
+
+// cmdVariableDelete removes a CI/CD variable.
+//
+//	<host> variable-delete <project|group> <key> [--env scope]
+func cmdVariableDelete(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: variable-delete <project|group> <key> [--env scope]")
+	}
+	fs := flag.NewFlagSet("variable-delete", flag.ExitOnError)
+	env := fs.String("env", "", "environment scope of the variable to delete")
+	_ = fs.Parse(args[2:])
+	base, err := projectOrGroupBase(c, args[0])
+	if err != nil {
+		die("%s", err)
+	}
+	params := url.Values{}
+	if *env != "" {
+		params.Set("filter[environment_scope]", *env)
+	}
+	if err := c.delete(base+"/variables/"+url.PathEscape(args[1]), params); err != nil {
+		die("variable-delete: %v", err)
+	}
+	fmt.Printf("%s deleted from %s\n", args[1], args[0])
+}
+
+// majorMinor parses the "17.4" of a version like "17.4.1" or "v17.4.0-ee".
+func majorMinor(v string) (major, minor int, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(strings.TrimFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	return major, minor, err1 == nil && err2 == nil
+}
+
+// cmdRunners lists runners with status, tags, version, last contact and
+// running jobs, and flags runners that are offline, paused, or more than
+// --max-behind minor versions behind the GitLab instance.
+//
+//	<host> runners [--group g | --project p | --all] [--tags a,b] [--max-behind 2]
+//
+// Without a scope, lists runners the current user can see. --all needs
+// admin. --tags keeps runners that could pick up a job with those tags.
+func cmdRunners(c *apiClient, args []string) {
+	fs := flag.NewFlagSet("runners", flag.ExitOnError)
+	group := fs.String("group", "", "runners available to a group")
+	project := fs.String("project", "", "runners available to a project")
+	all := fs.Bool("all", false, "every runner on the instance (admin)")
+	tags := fs.String("tags", "", "only runners that can run jobs with these tags")
+	maxBehind := fs.Int("max-behind", 2, "minor versions behind GitLab before a runner is flagged outdated")
+	concurrency := fs.Int("concurrency", 4, "runner details fetched in parallel")
+	_ = fs.Parse(args)
+
+	endpoint := "/runners"
+	switch {
+	case *group != "":
+		endpoint = "/groups/" + url.PathEscape(*group) + "/runners"
+	case *project != "":
+		endpoint = "/projects/" + url.PathEscape(*project) + "/runners"
+	case *all:
+		endpoint = "/runners/all"
+	}
+	items, err := c.getAll(endpoint, nil)
+	if err != nil {
+		die("%s", err)
+	}
+
+	gitlabMajor, gitlabMinor, haveVersion := 0, 0, false
+	if data, err := c.get("/version", nil); err == nil {
+		var vm map[string]any
+		json.Unmarshal(data, &vm)
+		gitlabMajor, gitlabMinor, haveVersion = majorMinor(jsonStr(vm, "version"))
+	}
+
+	runners := make([]map[string]any, len(items))
+	running := make([]int, len(items))
+	forEachLimited(len(items), *concurrency, func(i int) {
+		id := jsonStr(asMap(items[i]), "id")
+		runners[i] = asMap(items[i])
+		if data, err := c.get("/runners/"+id, nil); err == nil {
+			var detail map[string]any
+			json.Unmarshal(data, &detail)
+			runners[i] = detail
+		}
+		if jobs, err := c.getAll("/runners/"+id+"/jobs", url.Values{"status": {"running"}}); err == nil {
+			running[i] = len(jobs)
+		}
+	})
+
+	want := splitCSV(*tags)
+	var total, online, offline, outdated, paused, jobs int
+	for i, r := range runners {
+		tagList := toStringSlice(jsonArr(r, "tag_list"))
+		if len(want) > 0 {
+			have := map[string]bool{}
+			for _, t := range tagList {
+				have[t] = true
+			}
+			ok := true
+			for _, t := range want {
+				ok = ok && have[t]
+			}
+			if !ok {
+				continue
+			}
+		}
+		total++
+		jobs += running[i]
+		status := strOr(jsonStr(r, "status"), "unknown")
+		var problems []string
+		switch status {
+		case "online":
+			online++
+		case "offline", "stale", "never_contacted":
+			offline++
+			problems = append(problems, status)
+		}
+		if r["paused"] == true || r["active"] == false {
+			paused++
+			problems = append(problems, "paused")
+		}
+		version := jsonStr(r, "version")
+		if major, minor, ok := majorMinor(version); ok && haveVersion {
+			if major < gitlabMajor || (major == gitlabMajor && gitlabMinor-minor > *maxBehind) {
+				outdated++
+				problems = append(problems, fmt.Sprintf("outdated (%d.%d vs GitLab %d.%d)", major, minor, gitlabMajor, gitlabMinor))
+			}
+		}
+
+		scope := strOr(jsonStr(r, "runner_type"), map[bool]string{true: "shared", false: "specific"}[r["is_shared"] == true])
+		fmt.Printf("#%s  %s  [%s]  %s\n", jsonStr(r, "id"), strOr(jsonStr(r, "description"), "(no description)"), status, scope)
+		tagInfo := strOr(strings.Join(tagList, ","), "none")
+		if r["run_untagged"] == true {
+			tagInfo += " (+untagged)"
+		}
+		fmt.Printf("  Version: %s  Tags: %s  Running jobs: %d\n", strOr(version, "?"), tagInfo, running[i])
+		fmt.Printf("  Last contact: %s  Platform: %s/%s\n", strOr(jsonStr(r, "contacted_at"), "never"),
+			strOr(jsonStr(r, "platform"), "?"), strOr(jsonStr(r, "architecture"), "?"))
+		if len(problems) > 0 {
+			fmt.Printf("  ! %s\n", strings.Join(problems, ", "))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Summary: %d runner(s): %d online, %d offline, %d paused, %d outdated; %d job(s) running\n",
+		total, online, offline, paused, outdated, jobs)
+	if len(want) > 0 && online == 0 {
+		fmt.Printf("No online runner carries tags %s: jobs requiring them will stay pending.\n", strings.Join(want, ","))
+	}
+}
+
+// ── Container registry tags ─────────────────────────────────
+
+type registryTag struct {
+	name    string
+	digest  string
+	size    int64
+	created time.Time // zero when the registry does not report it
+}
+
+// tagRule mirrors a container expiration policy: tags matching deleteRe
+// but not keepRe, beyond the keepN most recent, and created before
+// olderThan ago are expired. With policy set, the cleanup policy's own
+// safeguards also apply: "latest" and undated tags are never expired.
+type tagRule struct {
+	deleteRe  *regexp.Regexp
+	keepRe    *regexp.Regexp
+	keepN     int
+	olderThan time.Duration
+	policy    bool
+}
+
+// anchoredRegexp compiles a policy regex the way GitLab evaluates it:
+// against the whole tag name. An empty pattern matches nothing.
+func anchoredRegexp(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// parseAge parses "90d", "2w", or any Go duration such as "36h".
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && n >= 0 && strings.HasSuffix(s, suffix) {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q (want e.g. 30d, 2w, 36h)", s)
+	}
+	return d, nil
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// expiredTags applies rule to tags in the order GitLab's cleanup does:
+// keep names not matching deleteRe or matching keepRe (and, for a policy,
+// "latest"), keep the keepN most recent of the rest, then keep anything
+// newer than olderThan. Tags that would otherwise expire but have no
+// creation date are returned as undated when their age matters: always
+// for a policy, and with olderThan for a manual rule.
+func expiredTags(tags []registryTag, rule tagRule, now time.Time) (expired, undated []registryTag) {
+	var candidates []registryTag
+	for _, t := range tags {
+		if (rule.policy && t.name == "latest") || !rule.deleteRe.MatchString(t.name) {
+			continue
+		}
+		if rule.keepRe != nil && rule.keepRe.MatchString(t.name) {
+			continue
+		}
+		candidates = append(candidates, t)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ci, cj := candidates[i].created, candidates[j].created
+		if ci.IsZero() != cj.IsZero() {
+			return ci.IsZero() // undated tags count as newest
+		}
+		return ci.After(cj)
+	})
+	if rule.keepN > 0 {
+		candidates = candidates[min(rule.keepN, len(candidates)):]
+	}
+	for _, t := range candidates {
+		if t.created.IsZero() && (rule.policy || rule.olderThan > 0) {
+			undated = append(undated, t)
+			continue
+		}
+		if rule.olderThan > 0 && now.Sub(t.created) < rule.olderThan {
+			continue
+		}
+		expired = append(expired, t)
+	}
+	return expired, undated
+}
+
+// registryRepoID resolves a repository id or path (as shown by
+// registries) to its numeric id.
+func registryRepoID(c *apiClient, encoded, ref string) string {
+	if _, err := strconv.Atoi(ref); err == nil {
+		return ref
+	}
+	repos, err := c.getAll("/projects/"+encoded+"/registry/repositories", nil)
+	if err != nil {
+		die("%s", err)
+	}
+	for _, r := range repos {
+		rm := asMap(r)
+		if jsonStr(rm, "path") == ref || jsonStr(rm, "name") == ref {
+			return jsonStr(rm, "id")
+		}
+	}
+	die("no registry repository %q (see registries)", ref)
+	return ""
+}
+
+// registryTags lists a repository's tags with digest, size and creation
+// date. Tags whose manifest is gone (404) are skipped, as GitLab's cleanup
+// does. Other detail failures are returned as an error alongside the tags
+// that could be read.
+func registryTags(c *apiClient, encoded, repoID string, concurrency int) ([]registryTag, error) {
+	base := "/projects/" + encoded + "/registry/repositories/" + repoID + "/tags"
+	items, err := c.getAll(base, nil)
+	if err != nil {
+		die("%s", err)
+	}
+	tags := make([]registryTag, len(items))
+	ok := make([]bool, len(items))
+	errs := make([]error, len(items))
+	forEachLimited(len(items), concurrency, func(i int) {
+		name := jsonStr(asMap(items[i]), "name")
+		data, err := c.get(base+"/"+url.PathEscape(name), nil)
+		if err != nil {
+			var ae *apiError
+			if !errors.As(err, &ae) || ae.StatusCode != 404 {
+				errs[i] = fmt.Errorf("tag %s: %s", name, strings.TrimSpace(err.Error()))
+			}
+			return
+		}
+		var d map[string]any
+		json.Unmarshal(data, &d)
+		t := registryTag{name: name, digest: jsonStr(d, "digest")}
+		if n, ok := d["total_size"].(float64); ok {
+			t.size = int64(n)
+		}
+		if ts, err := time.Parse(time.RFC3339, jsonStr(d, "created_at")); err == nil {
+			t.created = ts
+		}
+		tags[i], ok[i] = t, true
+	})
+	var out []registryTag
+	for i, t := range tags {
+		if ok[i] {
+			out = append(out, t)
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+func printTags(tags []registryTag) {
+	for _, t := range tags {
+		digest := strings.TrimPrefix(t.digest, "sha256:")
+		if len(digest) > 12 {
+			digest = digest[:12]
+		}
+		created := "unknown"
+		if !t.created.IsZero() {
+			created = t.created.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("  %-40s %-12s %10s  %s\n", t.name, strOr(digest, "-"), humanBytes(t.size), created)
+	}
+}
+
+func sumSizes(tags []registryTag) int64 {
+	var n int64
+	for _, t := range tags {
+		n += t.size
+	}
+	return n
+}
+
+// cmdRegistryTags lists the tags of one registry repository.
+//
+//	<host> registry-tags <project> <repo-id|path> [--sort created|name|size]
+func cmdRegistryTags(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: registry-tags <project> <repo-id|path> [--sort created|name|size]")
+	}
+	fs := flag.NewFlagSet("registry-tags", flag.ExitOnError)
+	sortBy := fs.String("sort", "created", "created (newest first), name, or size (largest first)")
+	concurrency := fs.Int("concurrency", 4, "tag details fetched in parallel")
+	_ = fs.Parse(args[2:])
+
+	encoded := url.PathEscape(args[0])
+	tags, err := registryTags(c, encoded, registryRepoID(c, encoded, args[1]), *concurrency)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not read some tags:\n%s\n", indentLines(err.Error(), "  "))
+	}
+	switch *sortBy {
+	case "created":
+		sort.SliceStable(tags, func(i, j int) bool { return tags[i].created.After(tags[j].created) })
+	case "name":
+		sort.SliceStable(tags, func(i, j int) bool { return tags[i].name < tags[j].name })
+	case "size":
+		sort.SliceStable(tags, func(i, j int) bool { return tags[i].size > tags[j].size })
+	default:
+		die("--sort must be created, name, or size")
+	}
+	fmt.Printf("  %-40s %-12s %10s  %s\n", "TAG", "DIGEST", "SIZE", "CREATED")
+	printTags(tags)
+	fmt.Printf("\n%d tag(s), %s total\n", len(tags), humanBytes(sumSizes(tags)))
+}
+
+// cmdRegistryDeleteTags deletes the tags selected by the same rules as a
+// cleanup policy, one by one, so the result is known when it returns.
+// Unlike the policy, "latest" is not special, and undated tags are only
+// skipped (and listed) when --older-than is given. Nothing is deleted if
+// any tag's details cannot be read, since --keep-n would be unreliable.
+//
+//	<host> registry-delete-tags <project> <repo-id|path> --name-regex re
+//	                            [--keep-regex re] [--keep-n N] [--older-than 30d] [--dry-run]
+func cmdRegistryDeleteTags(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: registry-delete-tags <project> <repo-id|path> --name-regex re [--keep-regex re] [--keep-n N] [--older-than 30d] [--dry-run]")
+	}
+	fs := flag.NewFlagSet("registry-delete-tags", flag.ExitOnError)
+	nameRegex := fs.String("name-regex", "", "delete tags whose whole name matches (required; '.*' for all)")
+	keepRegex := fs.String("keep-regex", "", "never delete tags whose whole name matches")
+	keepN := fs.Int("keep-n", 0, "keep the N most recent matching tags")
+	olderThan := fs.String("older-than", "", "only delete tags older than this (e.g. 30d)")
+	dryRun := fs.Bool("dry-run", false, "print the tags that would be deleted")
+	concurrency := fs.Int("concurrency", 4, "requests in parallel")
+	_ = fs.Parse(args[2:])
+	if *nameRegex == "" {
+		die("registry-delete-tags: --name-regex is required (use '.*' to consider every tag)")
+	}
+
+	rule := tagRule{keepN: *keepN}
+	var err error
+	if rule.deleteRe, err = anchoredRegexp(*nameRegex); err != nil {
+		die("--name-regex: %v", err)
+	}
+	if *keepRegex != "" {
+		if rule.keepRe, err = anchoredRegexp(*keepRegex); err != nil {
+			die("--keep-regex: %v", err)
+		}
+	}
+	if *olderThan != "" {
+		if rule.olderThan, err = parseAge(*olderThan); err != nil {
+			die("--older-than: %v", err)
+		}
+	}
+
+	encoded := url.PathEscape(args[0])
+	repoID := registryRepoID(c, encoded, args[1])
+	tags, err := registryTags(c, encoded, repoID, *concurrency)
+	if err != nil {
+		die("could not read some tags, nothing deleted:\n%s", indentLines(err.Error(), "  "))
+	}
+	expired, undated := expiredTags(tags, rule, time.Now())
+	if len(undated) > 0 {
+		fmt.Printf("Skipping %d matching tag(s) with no creation date (age unknown for --older-than):\n", len(undated))
+		printTags(undated)
+		fmt.Println()
+	}
+	if len(expired) == 0 {
+		fmt.Printf("No tags selected (%d tag(s) in repository).\n", len(tags))
+		return
+	}
+	if *dryRun {
+		fmt.Printf("Would delete %d of %d tag(s), up to %s:\n", len(expired), len(tags), humanBytes(sumSizes(expired)))
+		printTags(expired)
+		return
+	}
+
+	failures := make([]string, len(expired))
+	forEachLimited(len(expired), *concurrency, func(i int) {
+		endpoint := "/projects/" + encoded + "/registry/repositories/" + repoID + "/tags/" + url.PathEscape(expired[i].name)
+		if err := c.delete(endpoint, nil); err != nil {
+			failures[i] = strings.TrimSpace(err.Error())
+		}
+	})
+	failed := 0
+	for i, t := range expired {
+		if failures[i] != "" {
+			failed++
+			fmt.Printf("  FAILED %s: %s\n", t.name, failures[i])
+		} else {
+			fmt.Printf("  deleted %s\n", t.name)
+		}
+	}
+	fmt.Printf("\nDeleted %d of %d selected tag(s)", len(expired)-failed, len(expired))
+	if failed > 0 {
+		fmt.Printf(", %d failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println()
+}
+
+// cmdCleanupSimulate evaluates the project's container expiration policy
+// locally against every registry repository and prints the tags it would
+// remove. Nothing is deleted, and the policy need not be enabled.
+//
+//	<host> cleanup-simulate <project> [--repo id|path]
+func cmdCleanupSimulate(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: cleanup-simulate <project> [--repo id|path]")
+	}
+	fs := flag.NewFlagSet("cleanup-simulate", flag.ExitOnError)
+	repo := fs.String("repo", "", "only this registry repository")
+	concurrency := fs.Int("concurrency", 4, "tag details fetched in parallel")
+	_ = fs.Parse(args[1:])
+
+	encoded := url.PathEscape(args[0])
+	data, err := c.get("/projects/"+encoded, nil)
+	if err != nil {
+		die("%s", err)
+	}
+	var pm map[string]any
+	json.Unmarshal(data, &pm)
+	policy := jsonMap(pm, "container_expiration_policy")
+	if policy == nil {
+		die("%s has no container expiration policy (registry disabled, or not visible to you)", args[0])
+	}
+
+	nameRegex := jsonStr(policy, "name_regex")
+	if nameRegex == "" {
+		nameRegex = jsonStr(policy, "name_regex_delete")
+	}
+	keepRegex := jsonStr(policy, "name_regex_keep")
+	rule := tagRule{policy: true}
+	if n, ok := policy["keep_n"].(float64); ok {
+		rule.keepN = int(n)
+	}
+	if rule.deleteRe, err = anchoredRegexp(nameRegex); err != nil {
+		die("policy name_regex: %v", err)
+	}
+	if keepRegex != "" {
+		if rule.keepRe, err = anchoredRegexp(keepRegex); err != nil {
+			die("policy name_regex_keep: %v", err)
+		}
+	}
+	if s := jsonStr(policy, "older_than"); s != "" {
+		if rule.olderThan, err = parseAge(s); err != nil {
+			die("policy older_than: %v", err)
+		}
+	}
+
+	enabled := "disabled"
+	if policy["enabled"] == true {
+		enabled = "enabled, next run " + strOr(jsonStr(policy, "next_run_at"), "unscheduled")
+	}
+	fmt.Printf("Cleanup policy for %s (%s)\n", args[0], enabled)
+	fmt.Printf("  Cadence: %s  Keep most recent: %s  Older than: %s\n",
+		strOr(jsonStr(policy, "cadence"), "-"), strOr(jsonStr(policy, "keep_n"), "-"), strOr(jsonStr(policy, "older_than"), "-"))
+	fmt.Printf("  Remove tags matching: %s\n", strOr(nameRegex, "(none)"))
+	fmt.Printf("  Keep tags matching:   %s\n\n", strOr(keepRegex, "(none)"))
+	if nameRegex == "" {
+		fmt.Println("The policy's name regex is empty, so it removes nothing.")
+		return
+	}
+
+	var repoIDs, repoPaths []string
+	if *repo != "" {
+		repoIDs, repoPaths = []string{registryRepoID(c, encoded, *repo)}, []string{*repo}
+	} else {
+		repos, err := c.getAll("/projects/"+encoded+"/registry/repositories", nil)
+		if err != nil {
+			die("%s", err)
+		}
+		for _, r := range repos {
+			repoIDs = append(repoIDs, jsonStr(asMap(r), "id"))
+			repoPaths = append(repoPaths, jsonStr(asMap(r), "path"))
+		}
+	}
+
+	now := time.Now()
+	var total, removed int
+	var reclaim int64
+	for i, id := range repoIDs {
+		tags, err := registryTags(c, encoded, id, *concurrency)
+		expired, undated := expiredTags(tags, rule, now)
+		total += len(tags)
+		removed += len(expired)
+		reclaim += sumSizes(expired)
+		fmt.Printf("[%s] %s: would remove %d of %d tag(s)\n", id, repoPaths[i], len(expired), len(tags))
+		printTags(expired)
+		if len(undated) > 0 {
+			fmt.Printf("  kept %d matching tag(s) with no creation date\n", len(undated))
+		}
+		if err != nil {
+			fmt.Printf("  warning: could not read some tags, so this is incomplete:\n%s\n", indentLines(err.Error(), "    "))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Summary: %d of %d tag(s) in %d repo(s) would be removed, up to %s\n",
+		removed, total, len(repoIDs), humanBytes(reclaim))
+}
+
+// ── DORA metrics ────────────────────────────────────────────
+
+// doraDeploy is one successful deployment with the changes it shipped.
+type doraDeploy struct {
+	id       string
+	sha      string
+	at       time.Time
+	leads    []time.Duration // first commit → deploy, one per change
+	hotfix   bool            // ships an MR with a failure label
+	rollback bool            // redeploys an earlier, different SHA
+	failed   bool            // followed by a rollback or hotfix
+	restore  time.Duration   // until the next deploy, when failed
+}
+
+// deployTime prefers the deployment's finish time, then its job's, then
+// when the record was last updated.
+func deployTime(d map[string]any) time.Time {
+	for _, s := range []string{jsonStr(d, "finished_at"), jsonStr(jsonMap(d, "deployable"), "finished_at"), jsonStr(d, "updated_at"), jsonStr(d, "created_at")} {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// earliestCommit returns the oldest authored date among commits.
+func earliestCommit(commits []any) time.Time {
+	var first time.Time
+	for _, cm := range commits {
+		m := asMap(cm)
+		t, err := time.Parse(time.RFC3339, strOr(jsonStr(m, "authored_date"), jsonStr(m, "created_at")))
+		if err == nil && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	return first
+}
+
+// doraChanges fills in lead times and the hotfix flag for deployment i.
+// Lead time is per MR, from its first commit; deployments with no linked
+// MRs (e.g. created through the API) fall back to the commits between the
+// previous deployment's SHA and this one.
+func doraChanges(c *apiClient, encoded string, deploys []*doraDeploy, i int, failureLabels map[string]bool) {
+	d := deploys[i]
+	if d.rollback {
+		return // ships no new changes
+	}
+	mrs, err := c.getAll("/projects/"+encoded+"/deployments/"+d.id+"/merge_requests", nil)
+	if err == nil && len(mrs) > 0 {
+		for _, m := range mrs {
+			mr := asMap(m)
+			for _, l := range toStringSlice(jsonArr(mr, "labels")) {
+				if failureLabels[strings.ToLower(l)] {
+					d.hotfix = true
+				}
+			}
+			commits, err := c.getAll("/projects/"+encoded+"/merge_requests/"+jsonStr(mr, "iid")+"/commits", nil)
+			if first := earliestCommit(commits); err == nil && !first.IsZero() && !first.After(d.at) {
+				d.leads = append(d.leads, d.at.Sub(first))
+			}
+		}
+		return
+	}
+	if i == 0 || deploys[i-1].sha == "" || deploys[i-1].sha == d.sha {
+		return
+	}
+	data, err := c.get("/projects/"+encoded+"/repository/compare", url.Values{"from": {deploys[i-1].sha}, "to": {d.sha}})
+	if err != nil {
+		return
+	}
+	var cmp map[string]any
+	json.Unmarshal(data, &cmp)
+	for _, cm := range jsonArr(cmp, "commits") {
+		if first := earliestCommit([]any{cm}); !first.IsZero() && !first.After(d.at) {
+			d.leads = append(d.leads, d.at.Sub(first))
+		}
+	}
+}
+
+// markRollbacks flags deployments of a SHA that was deployed before, but
+// not by the previous deployment.
+func markRollbacks(deploys []*doraDeploy) {
+	seen := map[string]bool{}
+	for i, d := range deploys {
+		if i > 0 && d.sha != "" && seen[d.sha] && deploys[i-1].sha != d.sha {
+			d.rollback = true
+		}
+		seen[d.sha] = true
+	}
+}
+
+// markFailures marks the deployment before a rollback or hotfix as a
+// failed change, restored by that next deployment.
+func markFailures(deploys []*doraDeploy) {
+	for i := 1; i < len(deploys); i++ {
+		if deploys[i].rollback || deploys[i].hotfix {
+			deploys[i-1].failed = true
+			deploys[i-1].restore = deploys[i].at.Sub(deploys[i-1].at)
+		}
+	}
+}
+
+func medianDuration(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	s := append([]time.Duration(nil), ds...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
+
+// shortDuration renders d as "3d 4h", "5h 12m" or "42m"; "-" when unknown.
+func shortDuration(d time.Duration, known bool) string {
+	if !known {
+		return "-"
+	}
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
+
+// weekStart returns the Monday 00:00 UTC of t's week.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC().Truncate(24 * time.Hour)
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
+
+type doraWeek struct {
+	Week            string  `json:"week"`
+	Deployments     int     `json:"deployments"`
+	Failures        int     `json:"failures"`
+	FailureRate     float64 `json:"change_failure_rate"`
+	LeadTimeHours   float64 `json:"median_lead_time_hours,omitempty"`
+	RestoreHours    float64 `json:"median_time_to_restore_hours,omitempty"`
+	leads, restores []time.Duration
+}
+
+// cmdDORA computes the four DORA metrics for one environment from the
+// deployments API, deployment MRs and their commits, so it works on
+// tiers without GitLab's built-in DORA endpoints.
+//
+//	<host> dora <project> [--env production] [--since 90d] [--lookback 30d|all] [--failure-labels hotfix,rollback] [--json]
+//
+// Deployment frequency counts successful deployments. Lead time runs from
+// an MR's first commit to the deployment that shipped it. A deployment is
+// a failed change when the next one is a rollback (redeploys an older SHA)
+// or ships an MR with a failure label; time to restore is the gap between
+// the two. Rollbacks are recognized only against SHAs deployed within
+// --lookback before --since (or inside the window); "all" scans the
+// environment's whole history.
+func cmdDORA(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: dora <project> [--env production] [--since 90d] [--lookback 30d|all] [--failure-labels hotfix,rollback] [--json]")
+	}
+	fs := flag.NewFlagSet("dora", flag.ExitOnError)
+	env := fs.String("env", "production", "environment name")
+	since := fs.String("since", "90d", "window: age like 90d/12w, or a YYYY-MM-DD date")
+	lookback := fs.String("lookback", "30d", "history before --since searched for rollback targets: age like 30d, or all")
+	labels := fs.String("failure-labels", "hotfix,rollback", "MR labels that mark a fix for a failed change")
+	asJSON := fs.Bool("json", false, "emit weekly series and summary as JSON")
+	concurrency := fs.Int("concurrency", 4, "deployments processed in parallel")
+	_ = fs.Parse(args[1:])
+
+	now := time.Now().UTC()
+	start, err := time.Parse("2006-01-02", *since)
+	if err != nil {
+		age, err := parseAge(*since)
+		if err != nil {
+			die("--since: %v", err)
+		}
+		start = now.Add(-age)
+	}
+	params := url.Values{
+		"environment": {*env},
+		"status":      {"success"},
+		"order_by":    {"updated_at"},
+		"sort":        {"asc"},
+	}
+	// Include deployments from before the window so the first one in it
+	// has a predecessor for the compare fallback, and a redeploy of an
+	// older SHA is recognized as a rollback.
+	if *lookback != "all" {
+		age, err := parseAge(*lookback)
+		if err != nil {
+			die("--lookback: %v", err)
+		}
+		params.Set("updated_after", start.Add(-age).Format(time.RFC3339))
+	}
+	failureLabels := map[string]bool{}
+	for _, l := range splitCSV(*labels) {
+		failureLabels[strings.ToLower(l)] = true
+	}
+
+	encoded := url.PathEscape(args[0])
+	items, err := c.getAll("/projects/"+encoded+"/deployments", params)
+	if err != nil {
+		die("%s", err)
+	}
+	var deploys []*doraDeploy
+	for _, it := range items {
+		d := asMap(it)
+		if at := deployTime(d); !at.IsZero() {
+			deploys = append(deploys, &doraDeploy{id: jsonStr(d, "id"), sha: jsonStr(d, "sha"), at: at})
+		}
+	}
+	sort.SliceStable(deploys, func(i, j int) bool { return deploys[i].at.Before(deploys[j].at) })
+	first := sort.Search(len(deploys), func(i int) bool { return !deploys[i].at.Before(start) })
+	if first == len(deploys) {
+		die("no successful deployments to %q since %s", *env, start.Format("2006-01-02"))
+	}
+
+	markRollbacks(deploys)
+	forEachLimited(len(deploys)-first, *concurrency, func(i int) {
+		doraChanges(c, encoded, deploys, first+i, failureLabels)
+	})
+	markFailures(deploys)
+	deploys = deploys[first:]
+
+	// A deployment stamped after the local clock (skew) still needs a
+	// bucket, so the series runs to whichever is later.
+	end := now
+	if last := deploys[len(deploys)-1].at; last.After(end) {
+		end = last
+	}
+	var weeks []*doraWeek
+	byWeek := map[string]*doraWeek{}
+	for w := weekStart(start); !w.After(end); w = w.AddDate(0, 0, 7) {
+		wk := &doraWeek{Week: w.Format("2006-01-02")}
+		weeks = append(weeks, wk)
+		byWeek[wk.Week] = wk
+	}
+	var allLeads, allRestores []time.Duration
+	failures := 0
+	for _, d := range deploys {
+		wk := byWeek[weekStart(d.at).Format("2006-01-02")]
+		wk.Deployments++
+		wk.leads = append(wk.leads, d.leads...)
+		allLeads = append(allLeads, d.leads...)
+		if d.failed {
+			failures++
+			wk.Failures++
+			wk.restores = append(wk.restores, d.restore)
+			allRestores = append(allRestores, d.restore)
+		}
+	}
+	for _, wk := range weeks {
+		if wk.Deployments > 0 {
+			wk.FailureRate = float64(wk.Failures) / float64(wk.Deployments)
+		}
+		wk.LeadTimeHours = medianDuration(wk.leads).Hours()
+		wk.RestoreHours = medianDuration(wk.restores).Hours()
+	}
+
+	days := end.Sub(start).Hours() / 24
+	rate := float64(failures) / float64(len(deploys))
+	if *asJSON {
+		printJSON(map[string]any{
+			"project":     args[0],
+			"environment": *env,
+			"since":       start.Format(time.RFC3339),
+			"weeks":       weeks,
+			"summary": map[string]any{
+				"deployments":                  len(deploys),
+				"deployments_per_week":         float64(len(deploys)) / days * 7,
+				"changes":                      len(allLeads),
+				"median_lead_time_hours":       medianDuration(allLeads).Hours(),
+				"failed_deployments":           failures,
+				"change_failure_rate":          rate,
+				"median_time_to_restore_hours": medianDuration(allRestores).Hours(),
+			},
+		})
+		return
+	}
+
+	fmt.Printf("DORA metrics for %s (%s) since %s\n\n", args[0], *env, start.Format("2006-01-02"))
+	fmt.Printf("%-10s  %7s  %10s  %8s  %6s  %10s\n", "WEEK", "DEPLOYS", "LEAD TIME", "FAILURES", "CFR", "RESTORE")
+	for _, wk := range weeks {
+		cfr := "-"
+		if wk.Deployments > 0 {
+			cfr = fmt.Sprintf("%.0f%%", wk.FailureRate*100)
+		}
+		fmt.Printf("%-10s  %7d  %10s  %8d  %6s  %10s\n", wk.Week, wk.Deployments,
+			shortDuration(medianDuration(wk.leads), len(wk.leads) > 0), wk.Failures, cfr,
+			shortDuration(medianDuration(wk.restores), len(wk.restores) > 0))
+	}
+	fmt.Println()
+	fmt.Printf("Deployment frequency: %d deployment(s), %.1f per week\n", len(deploys), float64(len(deploys))/days*7)
+	fmt.Printf("Lead time for changes: median %s over %d change(s)\n", shortDuration(medianDuration(allLeads), len(allLeads) > 0), len(allLeads))
+	fmt.Printf("Change failure rate: %.0f%% (%d of %d deployment(s) followed by a rollback or an MR labeled %s)\n",
+		rate*100, failures, len(deploys), strings.Join(splitCSV(*labels), "/"))
+	fmt.Printf("Time to restore: median %s over %d failure(s)\n", shortDuration(medianDuration(allRestores), len(allRestores) > 0), len(allRestores))
+}
+
+// ── Help ────────────────────────────────────────────────────
+
+func printHelp() {
+	fmt.Println(`Usage: gitlab-navigator <host> <command> [args...]
+
+Discovery:
+  discover [substring]                             Find GitLab hosts in ~/.netrc
+
+Query commands (host = hostname or unique substring from ~/.netrc):
+  <host> whoami                                    Current user
+  <host> test                                      Test connection
+  <host> graphql <query-file|-> [-v name=string | -v name:=json ...] [--paginate path.to.connection]
+                                                    Run a GraphQL query and print the data
+
+Activity & starred:
+  <host> starred [limit]                           Starred projects
+  <host> starred-activity [days] [limit]           Starred projects with recent activity
+                                                    and open MR counts (GraphQL)
+  <host> events [limit]                            Your recent activity feed
+  <host> project-events <project> [limit]          Project activity
+
+Projects:
+  <host> projects [limit]                          Your projects (by membership)
+  <host> project-info <project>                    Project details + statistics
+  <host> create-project <name> [path] [visibility] [namespace-id]
+                                                    Create a new project
+
+Merge requests:
+  <host> my-mrs [state] [limit]                    MRs assigned to you
+  <host> mr-review [state] [limit]                 MRs awaiting your review
+  <host> project-mrs <project> [state] [limit]     MRs in a project
+  <host> mr <project> <iid>                        MR details, approvals, head pipeline
+  <host> mr-changes <project> <iid> [--stat|--diff]
+                                                    MR changed files, diffstat or unified diff
+  <host> mr-create <project> --title "..." [--source b] [--target main]
+             [--desc "..."|--desc-file f|--desc-stdin] [--reviewers a,b]
+             [--assignees a,b] [--labels x,y] [--draft] [--squash]
+             [--remove-source-branch]               Open an MR (source: current git branch)
+  <host> mr-update <project> <iid> [--title] [--target] [--desc...]
+             [--reviewers a,b] [--labels|--add-labels|--remove-labels]
+             [--draft|--ready] [--close|--reopen]  Edit an MR
+  <host> mr-approve <project> <iid> [--sha SHA]    Approve an MR
+  <host> mr-unapprove <project> <iid>              Revoke your approval
+  <host> mr-merge <project> <iid> [--squash] [--remove-source-branch]
+             [--when-pipeline-succeeds] [--message "..."] [--sha SHA]
+                                                    Merge (or auto-merge) an MR
+  <host> mr-discussions <project> <iid> [--unresolved] [--system]
+                                                    Review threads with file:line anchors
+  <host> mr-reply <project> <iid> <discussion-id> --body "..." [--resolve]
+                                                    Reply to a thread
+  <host> mr-resolve <project> <iid> <discussion-id> [--unresolve]
+                                                    Resolve / reopen a thread
+  <host> mr-comment <project> <iid> --body "..." [--file path --line N [--old]]
+                                                    New thread, optionally on a diff line
+  <host> mr-owners <project> <iid>                 CODEOWNERS per changed file, sections
+                                                    still needing approval, suggested reviewers
+
+Issues:
+  <host> my-issues [state] [limit]                 Issues assigned to you
+  <host> project-issues <project> [state] [limit]  Issues in a project
+  <host> issue <project> <iid>                     Issue details
+  <host> issue-create <project> --title "..." [--desc "..."|--desc-file f|--desc-stdin]
+             [--labels x,y] [--assignees a,b] [--milestone M] [--due YYYY-MM-DD]
+             [--weight N] [--confidential] [--type issue|incident|task]
+                                                    Open an issue
+  <host> issue-update <project> <iid> [--title] [--desc...] [--labels|--add-labels|--remove-labels]
+             [--assignees a,b] [--milestone M] [--due D] [--weight N]
+             [--confidential true|false]           Edit an issue ("" clears)
+  <host> issue-close <project> <iid> [--comment "..."]
+                                                    Close an issue
+  <host> issue-reopen <project> <iid> [--comment "..."]
+                                                    Reopen an issue
+  <host> issue-note <project> <iid> --body "..." [--internal]
+                                                    Comment on an issue
+  <host> issue-link <project> <iid> <[project#]iid> [--type relates_to|blocks|is_blocked_by]
+                                                    Link two issues
+  <host> milestones <project|group> [active|closed|all]
+                                                    Milestones with dates and days remaining
+  <host> milestone-report <project|group> <id|title> [--format text|md]
+                                                    Progress, assignees, weights, burnup
+
+Pipelines:
+  <host> pipelines <project> [limit]               Recent pipelines
+  <host> pipeline <project> <id>                   Pipeline details + jobs
+  <host> job-log <project> <job-id> [--tail N] [--follow] [--raw]
+                                                    Cleaned job log (ANSI/sections stripped)
+  <host> pipeline-failures <project> <id> [--context N] [--tail N] [--max-lines N]
+                                                    Error regions from every failed job
+  <host> test-report <project> <id> [--slowest N]  JUnit summary + failed tests
+  <host> pipeline-run <project> <ref> [-v KEY=VAL ...] [--wait]
+                                                    Start a pipeline
+  <host> pipeline-wait <project> <id> [--timeout 30m]
+                                                    Wait, streaming job changes (exit 0 ok,
+                                                    1 failed, 2 timeout, 3 manual, 4 error)
+  <host> pipeline-retry <project> <id>             Retry failed jobs
+  <host> pipeline-cancel <project> <id>            Cancel a pipeline
+  <host> job-retry <project> <job-id>              Retry one job
+  <host> job-play <project> <job-id> [-v KEY=VAL]  Run a manual job
+  <host> artifacts <project> <job-id> [--path file] [--extract dir] [--out file]
+                                                    Download / extract job artifacts
+  <host> ci-lint <project> [--file .gitlab-ci.yml] [--ref b] [--dry-run] [--no-yaml]
+                                                    Validate CI config, show merged YAML;
+                                                    --dry-run lists jobs for the ref
+
+Code:
+  <host> branches <project> [limit]                List branches
+  <host> commits <project> [ref] [limit]           Recent commits
+  <host> tree <project> [path] [ref]               Directory listing
+  <host> file <project> <path> [ref]               Read file content
+  <host> compare <project> <from> <to> [--stat|--diff] [--straight]
+                                                    Commits and changes between refs
+  <host> commit <project> --branch b -m "msg" [--start-branch main]
+             [--create path[=local]] [--update path[=local]] [--delete path]
+             [--move old:new[=local]] [--mr] [--dry-run]
+                                                    Atomic multi-file commit, no clone needed
+
+Releases:
+  <host> releases <project> [limit]                Releases, newest first
+  <host> release-create <project> <tag> [--ref main] [--from prev-tag] [--dry-run]
+                                                    Create a release with notes generated
+                                                    from MRs merged since the previous tag
+
+Groups:
+  <host> groups [limit]                            Your groups
+  <host> group-projects <group> [limit]            Projects in a group
+  <host> stale <group> [--days 90] [--concurrency 4] [--delete-merged [--dry-run]]
+                                                    Merged/stale branches and inactive MRs
+                                                    across a group and its subgroups
+  <host> audit <group> --policy policy.yaml [--json] [--fail-on LOW|MED|HIGH]
+                                                    Check every project against a policy;
+                                                    HIGH/MED/LOW findings, exit 1 on violations
+
+DORA metrics:
+  <host> dora <project> [--env production] [--since 90d] [--lookback 30d|all] [--failure-labels hotfix,rollback] [--json]
+                                                    Deploy frequency, lead time, change
+                                                    failure rate, time to restore; weekly
+
+CI/CD settings:
+  <host> variables <project|group> [--reveal] [--env scope]
+                                                    Variable keys and flags (values hidden)
+  <host> variable-set <project|group> <key> [value|--value-file f|--value-stdin]
+             [--env scope] [--protected] [--masked] [--raw] [--file]
+                                                    Create or update a variable
+  <host> variable-delete <project|group> <key> [--env scope]
+                                                    Delete a variable
+  <host> runners [--group g|--project p|--all] [--tags a,b]
+                                                    Runner status, tags, version, running jobs;
+                                                    flags offline/paused/outdated runners
+
+Search:
+  <host> search <query> [scope] [limit]            Global search
+  <host> project-search <project> <query> [scope]  Project-scoped search
+
+Registry:
+  <host> registries <project>                      Container registry repos
+  <host> registry-tags <project> <repo-id|path> [--sort created|name|size]
+                                                    Tags with digest, size, created date
+  <host> registry-delete-tags <project> <repo-id|path> --name-regex re
+             [--keep-regex re] [--keep-n N] [--older-than 30d] [--dry-run]
+                                                    Delete matching tags
+  <host> cleanup-simulate <project> [--repo id|path]
+                                                    Tags the cleanup policy would remove
+
+Search scopes: projects, issues, merge_requests, milestones, blobs
+Project refs: use ID (numeric) or URL-encoded path (group%2Fproject)`)
+}
+
+// ── Main ────────────────────────────────────────────────────
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 || args[0] == "help" {
 		printHelp()
 		return
 	}
@@ -1492,11 +7576,26 @@ func main() {
 
 	hostname, entry, err := resolveHost(host, "gitlab")
 	if err != nil {
+		if command == "pipeline-wait" || command == "pipeline-run" {
+			toolFail("%s", err)
+		}
 		die("%s", err)
 	}
 	client := newClient(hostname, entry)
 
 	switch command {
+	case "dora":
+		cmdDORA(client, cmdArgs)
+	case "variables":
+		cmdVariables(client, cmdArgs)
+	case "variable-set":
+		cmdVariableSet(client, cmdArgs)
+	case "variable-delete":
+		cmdVariableDelete(client, cmdArgs)
+	case "runners":
+		cmdRunners(client, cmdArgs)
+	case "graphql":
+		cmdGraphQL(client, cmdArgs)
 	case "whoami":
 		cmdWhoami(client)
 	case "test":
@@ -1525,16 +7624,74 @@ func main() {
 		cmdMR(client, cmdArgs)
 	case "mr-changes":
 		cmdMRChanges(client, cmdArgs)
+	case "mr-create":
+		cmdMRCreate(client, cmdArgs)
+	case "mr-update":
+		cmdMRUpdate(client, cmdArgs)
+	case "mr-approve":
+		cmdMRApprove(client, cmdArgs, false)
+	case "mr-unapprove":
+		cmdMRApprove(client, cmdArgs, true)
+	case "mr-merge":
+		cmdMRMerge(client, cmdArgs)
+	case "mr-discussions":
+		cmdMRDiscussions(client, cmdArgs)
+	case "mr-reply":
+		cmdMRReply(client, cmdArgs)
+	case "mr-resolve":
+		cmdMRResolve(client, cmdArgs)
+	case "mr-owners":
+		cmdMROwners(client, cmdArgs)
+	case "mr-comment":
+		cmdMRComment(client, cmdArgs)
 	case "my-issues":
 		cmdMyIssues(client, cmdArgs)
 	case "project-issues":
 		cmdProjectIssues(client, cmdArgs)
 	case "issue":
 		cmdIssue(client, cmdArgs)
+	case "issue-create":
+		cmdIssueCreate(client, cmdArgs)
+	case "issue-update":
+		cmdIssueUpdate(client, cmdArgs)
+	case "issue-close":
+		cmdIssueState(client, cmdArgs, "close")
+	case "issue-reopen":
+		cmdIssueState(client, cmdArgs, "reopen")
+	case "issue-note":
+		cmdIssueNote(client, cmdArgs)
+	case "issue-link":
+		cmdIssueLink(client, cmdArgs)
+	case "milestones":
+		cmdMilestones(client, cmdArgs)
+	case "milestone-report":
+		cmdMilestoneReport(client, cmdArgs)
 	case "pipelines":
 		cmdPipelines(client, cmdArgs)
 	case "pipeline":
 		cmdPipeline(client, cmdArgs)
+	case "job-log":
+		cmdJobLog(client, cmdArgs)
+	case "pipeline-failures":
+		cmdPipelineFailures(client, cmdArgs)
+	case "pipeline-run":
+		cmdPipelineRun(client, cmdArgs)
+	case "pipeline-wait":
+		cmdPipelineWait(client, cmdArgs)
+	case "pipeline-retry":
+		cmdPipelineAction(client, cmdArgs, "retry")
+	case "pipeline-cancel":
+		cmdPipelineAction(client, cmdArgs, "cancel")
+	case "job-retry":
+		cmdJobRetry(client, cmdArgs)
+	case "job-play":
+		cmdJobPlay(client, cmdArgs)
+	case "artifacts":
+		cmdArtifacts(client, cmdArgs)
+	case "test-report":
+		cmdTestReport(client, cmdArgs)
+	case "ci-lint":
+		cmdCILint(client, cmdArgs)
 	case "branches":
 		cmdBranches(client, cmdArgs)
 	case "commits":
@@ -1543,6 +7700,18 @@ func main() {
 		cmdTree(client, cmdArgs)
 	case "file":
 		cmdFile(client, cmdArgs)
+	case "compare":
+		cmdCompare(client, cmdArgs)
+	case "commit":
+		cmdCommit(client, cmdArgs)
+	case "releases":
+		cmdReleases(client, cmdArgs)
+	case "release-create":
+		cmdReleaseCreate(client, cmdArgs)
+	case "stale":
+		cmdStale(client, cmdArgs)
+	case "audit":
+		cmdAudit(client, cmdArgs)
 	case "groups":
 		cmdGroups(client, cmdArgs)
 	case "group-projects":
@@ -1553,6 +7722,12 @@ func main() {
 		cmdProjectSearch(client, cmdArgs)
 	case "registries":
 		cmdRegistries(client, cmdArgs)
+	case "registry-tags":
+		cmdRegistryTags(client, cmdArgs)
+	case "registry-delete-tags":
+		cmdRegistryDeleteTags(client, cmdArgs)
+	case "cleanup-simulate":
+		cmdCleanupSimulate(client, cmdArgs)
 	case "help":
 		printHelp()
 	default:
@@ -0,0 +1,901 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodeownersRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// unanchored patterns match at any depth
+		{"README.md", "README.md", true},
+		{"README.md", "docs/README.md", true},
+		{"README.md", "README.md.bak", false},
+		{"README.md", "xREADME.md", false},
+		// a leading "/" anchors at the root
+		{"/README.md", "README.md", true},
+		{"/README.md", "docs/README.md", false},
+		{"/", "any/file.txt", true},
+		// "*" stops at "/"
+		{"*.rb", "foo.rb", true},
+		{"*.rb", "lib/foo.rb", true},
+		{"*.rb", "lib/foo.rb/x", false},
+		{"/docs/*", "docs/a.md", true},
+		{"/docs/*", "docs/a/b.md", false},
+		{"/docs/*.md", "docs/a.txt", false},
+		// without a trailing "/" the whole path must match
+		{"/docs", "docs", true},
+		{"/docs", "docs/x.md", false},
+		{"/docs", "docsx", false},
+		// a trailing "/" matches everything inside the directory
+		{"/docs/", "docs/x.md", true},
+		{"/docs/", "docs/a/b.md", true},
+		{"/docs/", "docs", false},
+		{"/docs/", "other/docs/x.md", false},
+		{"docs/", "docs/x.md", true},
+		{"docs/", "a/docs/x.md", true},
+		{"docs/", "a/docsx/x.md", false},
+		// "**" crosses directories
+		{"/docs/**/*.md", "docs/a.md", true},
+		{"/docs/**/*.md", "docs/a/b/c.md", true},
+		{"/docs/**/*.md", "docs/a/b.txt", false},
+		{"**/config.yml", "config.yml", true},
+		{"**/config.yml", "a/b/config.yml", true},
+		{"/lib/**", "lib/a/b.go", true},
+		{"/lib/**", "libx/a.go", false},
+		// "?" is one character other than "/"
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file12.txt", false},
+		{"file?.txt", "file/.txt", false},
+		// character classes
+		{"/log[0-9].txt", "log5.txt", true},
+		{"/log[0-9].txt", "logx.txt", false},
+		{"/data[!0-9].csv", "datax.csv", true},
+		{"/data[!0-9].csv", "data1.csv", false},
+		// escapes (after splitEscaped) and regexp metacharacters
+		{"#notes.md", "#notes.md", true},
+		{"/path with spaces/", "path with spaces/a.txt", true},
+		{`/a\*b`, "a*b", true},
+		{`/a\*b`, "axb", false},
+		{"/v1.0+build", "v1.0+build", true},
+		{"/v1.0+build", "v1x0+build", false},
+	}
+	for _, tt := range tests {
+		re, err := codeownersRegexp(tt.pattern)
+		if err != nil {
+			t.Errorf("codeownersRegexp(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := re.MatchString(tt.path); got != tt.want {
+			t.Errorf("%q matching %q = %v, want %v (regexp %s)", tt.pattern, tt.path, got, tt.want, re)
+		}
+	}
+}
+
+func TestCodeownersRegexpErrors(t *testing.T) {
+	for _, pattern := range []string{"", "[abc", "/docs/[!x"} {
+		if _, err := codeownersRegexp(pattern); err == nil {
+			t.Errorf("codeownersRegexp(%q): want error", pattern)
+		}
+	}
+}
+
+func TestSplitEscaped(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"*.go @a @b", []string{"*.go", "@a", "@b"}},
+		{"/docs/\t\t@docs", []string{"/docs/", "@docs"}},
+		{`/path\ with\ spaces/ @x`, []string{"/path with spaces/", "@x"}},
+		{`\#notes.md @y`, []string{"#notes.md", "@y"}},
+		{`/a\*b @z`, []string{`/a\*b`, "@z"}},
+	}
+	for _, tt := range tests {
+		if got := splitEscaped(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitEscaped(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+const testCodeowners = `# Top-level rules
+* @everyone
+/docs/ @docs-team
+\#notes.md @pound
+/path\ with\ spaces/ @spaces
+!/docs/internal/
+
+[Backend][2] @backend-lead
+/app/
+*.go @go-dev
+
+^[Optional Docs] @writers
+*.md
+
+# merged into [Backend]
+[backend]
+/api/ @api
+/bad[ @x
+/owners bogus
+`
+
+func TestParseCodeowners(t *testing.T) {
+	co, warnings := parseCodeowners(testCodeowners)
+
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "/bad[") || !strings.Contains(warnings[1], `"bogus"`) {
+		t.Errorf("warnings = %q, want the bad pattern and the bogus owner", warnings)
+	}
+
+	type section struct {
+		name          string
+		optional      bool
+		approvals     int
+		defaultOwners []string
+	}
+	var gotSections []section
+	for _, s := range co.sections {
+		gotSections = append(gotSections, section{s.name, s.optional, s.approvals, s.defaultOwners})
+	}
+	wantSections := []section{
+		{"Codeowners", false, 1, nil},
+		{"Backend", false, 2, []string{"@backend-lead"}},
+		{"Optional Docs", true, 1, []string{"@writers"}},
+	}
+	if !reflect.DeepEqual(gotSections, wantSections) {
+		t.Errorf("sections = %+v, want %+v", gotSections, wantSections)
+	}
+
+	var excludes int
+	for _, r := range co.rules {
+		if r.exclude {
+			excludes++
+			if r.pattern != "/docs/internal/" || r.section.name != "Codeowners" {
+				t.Errorf("exclusion rule = %q in %s", r.pattern, r.section.name)
+			}
+		}
+	}
+	if excludes != 1 {
+		t.Errorf("got %d exclusion rules, want 1", excludes)
+	}
+}
+
+func TestCodeownersOwners(t *testing.T) {
+	co, _ := parseCodeowners(testCodeowners)
+	tests := []struct {
+		path string
+		want map[string][]string // section → effective owners
+	}{
+		{"main.txt", map[string][]string{"Codeowners": {"@everyone"}}},
+		{"docs/guide.md", map[string][]string{"Codeowners": {"@docs-team"}, "Optional Docs": {"@writers"}}},
+		// the exclusion drops the section, not other sections
+		{"docs/internal/x.md", map[string][]string{"Optional Docs": {"@writers"}}},
+		{"#notes.md", map[string][]string{"Codeowners": {"@pound"}, "Optional Docs": {"@writers"}}},
+		{"path with spaces/a.txt", map[string][]string{"Codeowners": {"@spaces"}}},
+		// a rule without owners uses the section's default owners
+		{"app/config.yml", map[string][]string{"Codeowners": {"@everyone"}, "Backend": {"@backend-lead"}}},
+		// the last matching rule in a section wins
+		{"app/main.go", map[string][]string{"Codeowners": {"@everyone"}, "Backend": {"@go-dev"}}},
+		{"api/handler.go", map[string][]string{"Codeowners": {"@everyone"}, "Backend": {"@api"}}},
+	}
+	for _, tt := range tests {
+		got := map[string][]string{}
+		for s, r := range co.owners(tt.path) {
+			got[s.name] = r.effectiveOwners()
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("owners(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCountDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		diff       string
+		adds, dels int
+	}{
+		{"plain hunk", "@@ -1,2 +1,2 @@\n-old\n+new\n ctx\n", 1, 1},
+		{"file headers skipped", "--- a/x.sql\n+++ b/x.sql\n@@ -1 +1 @@\n-a\n+b\n", 1, 1},
+		{"sql comment removed", "@@ -1,2 +1,1 @@\n-- sql comment\n keep\n", 0, 1},
+		{"line starting with ++ added", "@@ -0,0 +1 @@\n+++ counter\n", 1, 0},
+		{"two hunks", "@@ -1 +1 @@\n-a\n+b\n@@ -9 +9,2 @@\n c\n+d\n", 2, 1},
+	}
+	for _, tt := range tests {
+		if adds, dels := countDiff(tt.diff); adds != tt.adds || dels != tt.dels {
+			t.Errorf("%s: got +%d -%d, want +%d -%d", tt.name, adds, dels, tt.adds, tt.dels)
+		}
+	}
+}
+
+func TestErrorRegionPrefersStepScript(t *testing.T) {
+	lines := []traceLine{
+		{"Fetching changes", "get_sources"},
+		{"$ make test", "step_script"},
+		{"--- FAIL: TestThing", "step_script"},
+		{"ERROR: Job failed: exit code 2", "step_script"},
+		{"$ ./notify.sh", "after_script"},
+		{"notified", "after_script"},
+		{"Uploading artifacts", "upload_artifacts_on_failure"},
+	}
+	got := errorRegion(lines, 1, 2, 20)
+	want := []string{"$ make test", "--- FAIL: TestThing", "ERROR: Job failed: exit code 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errorRegion = %q, want %q", got, want)
+	}
+
+	// Without a script section, fall back to the last custom section.
+	custom := []traceLine{{"setting up", "my_setup"}, {"fatal: boom", "my_setup"}, {"$ ./notify.sh", "after_script"}}
+	if got := errorRegion(custom, 0, 1, 20); !reflect.DeepEqual(got, []string{"fatal: boom"}) {
+		t.Errorf("fallback errorRegion = %q", got)
+	}
+}
+
+func TestTransientErr(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("request failed: connection reset"), true},
+		{&apiError{StatusCode: 429}, true},
+		{&apiError{StatusCode: 502}, true},
+		{&apiError{StatusCode: 401}, false},
+		{&apiError{StatusCode: 404}, false},
+	}
+	for _, tt := range tests {
+		if got := transientErr(tt.err); got != tt.want {
+			t.Errorf("transientErr(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseGraphQLVars(t *testing.T) {
+	got, err := parseGraphQLVars([]string{"iid=42", "path=a/b", "first:=50", "draft:=true", `labels:=["bug"]`, "empty=", "eq=a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"iid":    "42",
+		"path":   "a/b",
+		"first":  float64(50),
+		"draft":  true,
+		"labels": []any{"bug"},
+		"empty":  "",
+		"eq":     "a=b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGraphQLVars = %#v, want %#v", got, want)
+	}
+	for _, bad := range []string{"noequals", "=x", ":=1", "n:=not json"} {
+		if _, err := parseGraphQLVars([]string{bad}); err == nil {
+			t.Errorf("parseGraphQLVars(%q): want error", bad)
+		}
+	}
+}
+
+func TestExpiredTags(t *testing.T) {
+	now := time.Date(2025, 10, 18, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tags := []registryTag{
+		{name: "latest", created: now.Add(-100 * day)},
+		{name: "v1.0", created: now.Add(-90 * day)},
+		{name: "mr-1", created: now.Add(-60 * day)},
+		{name: "mr-2", created: now.Add(-40 * day)},
+		{name: "mr-3", created: now.Add(-5 * day)},
+		{name: "mr-undated"},
+	}
+	names := func(tags []registryTag) []string {
+		var out []string
+		for _, t := range tags {
+			out = append(out, t.name)
+		}
+		return out
+	}
+	all := regexp.MustCompile(`^(?:.*)$`)
+	mr := regexp.MustCompile(`^(?:mr-.*)$`)
+	tests := []struct {
+		name             string
+		rule             tagRule
+		expired, undated []string
+	}{
+		{"manual rule deletes latest and undated tags", tagRule{deleteRe: all}, []string{"mr-undated", "mr-3", "mr-2", "mr-1", "v1.0", "latest"}, nil},
+		{"manual rule with age lists undated tags", tagRule{deleteRe: mr, olderThan: 30 * day}, []string{"mr-2", "mr-1"}, []string{"mr-undated"}},
+		{"policy keeps latest and undated tags", tagRule{deleteRe: all, policy: true}, []string{"mr-3", "mr-2", "mr-1", "v1.0"}, []string{"mr-undated"}},
+		{"keep-n counts undated tags as newest", tagRule{deleteRe: mr, keepN: 2}, []string{"mr-2", "mr-1"}, nil},
+		{"keep regex", tagRule{deleteRe: all, keepRe: regexp.MustCompile(`^(?:v.*|latest)$`), olderThan: 30 * day}, []string{"mr-2", "mr-1"}, []string{"mr-undated"}},
+	}
+	for _, tt := range tests {
+		expired, undated := expiredTags(tags, tt.rule, now)
+		if got := names(expired); !reflect.DeepEqual(got, tt.expired) {
+			t.Errorf("%s: expired = %q, want %q", tt.name, got, tt.expired)
+		}
+		if got := names(undated); !reflect.DeepEqual(got, tt.undated) {
+			t.Errorf("%s: undated = %q, want %q", tt.name, got, tt.undated)
+		}
+	}
+}
+
+func TestDraftTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		draft bool
+		want  string
+	}{
+		{"Add retry", true, "Draft: Add retry"},
+		{"Draft: Add retry", true, "Draft: Add retry"},
+		{"WIP: Add retry", true, "Draft: Add retry"},
+		{"[Draft] Add retry", false, "Add retry"},
+		{"draft: Add retry", false, "Add retry"},
+		{"Drafting rules", false, "Drafting rules"},
+	}
+	for _, tt := range tests {
+		if got := draftTitle(tt.title, tt.draft); got != tt.want {
+			t.Errorf("draftTitle(%q, %v) = %q, want %q", tt.title, tt.draft, got, tt.want)
+		}
+	}
+}
+
+func TestDiffLineKind(t *testing.T) {
+	// old 1-3 → new 1-4 in the first hunk, old 10-12 → new 11-12 in the second.
+	diff := "@@ -1,3 +1,4 @@\n ctx1\n-gone2\n+new2\n+new3\n ctx3\n@@ -10,3 +11,2 @@\n ctx10\n-gone11\n ctx12\n"
+	tests := []struct {
+		name  string
+		line  int
+		old   bool
+		kind  string
+		other int
+	}{
+		{"context in first hunk", 1, false, "context", 1},
+		{"added line", 2, false, "added", 0},
+		{"second added line", 3, false, "added", 0},
+		{"context after additions", 4, false, "context", 3},
+		{"unchanged between hunks", 7, false, "unchanged", 6},
+		{"context in second hunk", 11, false, "context", 10},
+		{"context after a removal", 12, false, "context", 12},
+		{"unchanged after last hunk", 20, false, "unchanged", 20},
+		{"old context in first hunk", 1, true, "context", 1},
+		{"removed line", 2, true, "removed", 0},
+		{"old context shifted", 3, true, "context", 4},
+		{"old unchanged between hunks", 6, true, "unchanged", 7},
+		{"old removed in second hunk", 11, true, "removed", 0},
+		{"old unchanged after last hunk", 20, true, "unchanged", 20},
+	}
+	for _, tt := range tests {
+		kind, other := diffLineKind(diff, tt.line, tt.old)
+		if kind != tt.kind || other != tt.other {
+			t.Errorf("%s: diffLineKind(%d, old=%v) = %s, %d; want %s, %d", tt.name, tt.line, tt.old, kind, other, tt.kind, tt.other)
+		}
+	}
+
+	// Lines before the first hunk keep their number on both sides.
+	if kind, other := diffLineKind("@@ -5,1 +5,2 @@\n x\n+y\n", 2, false); kind != "unchanged" || other != 2 {
+		t.Errorf("before first hunk: %s, %d", kind, other)
+	}
+}
+
+func TestSplitRawDiff(t *testing.T) {
+	raw := "diff --git a/x.go b/x.go\n" +
+		"index 1..2 100644\n--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		// a path containing " b/"
+		"diff --git a/docs/a b/c.md b/docs/a b/c.md\n" +
+		"--- a/docs/a b/c.md\t\n+++ b/docs/a b/c.md\t\n@@ -1 +1 @@\n-x\n+y\n" +
+		// quoted path
+		"diff --git \"a/t\\303\\251st.txt\" \"b/t\\303\\251st.txt\"\n" +
+		"--- \"a/t\\303\\251st.txt\"\n+++ \"b/t\\303\\251st.txt\"\n@@ -1 +1 @@\n-1\n+2\n" +
+		// deletion whose removed line looks like a header
+		"diff --git a/old.sql b/old.sql\ndeleted file mode 100644\n--- a/old.sql\n+++ /dev/null\n@@ -1,2 +0,0 @@\n--- comment\n-select 1;\n" +
+		// pure rename: no ---/+++ lines
+		"diff --git a/from.txt b/to.txt\nsimilarity index 100%\nrename from from.txt\nrename to to.txt\n" +
+		// binary file
+		"diff --git a/img.png b/img.png\nBinary files a/img.png and b/img.png differ\n"
+	got := splitRawDiff(raw)
+	var keys []string
+	for k := range got {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	want := []string{"docs/a b/c.md", "img.png", "old.sql", "to.txt", "tést.txt", "x.go"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("keys = %q, want %q", keys, want)
+	}
+	if sec := got["x.go"]; !strings.HasPrefix(sec, "diff --git a/x.go") || !strings.HasSuffix(sec, "+b\n") {
+		t.Errorf("x.go section = %q", sec)
+	}
+	if sec := got["old.sql"]; !strings.Contains(sec, "--- comment\n") {
+		t.Errorf("old.sql section = %q", sec)
+	}
+
+	// A header naming different files with no other path line is dropped,
+	// not merged into the previous section.
+	got = splitRawDiff("diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -1 +1 @@\n-1\n+2\ndiff --git a/p b/q\nold mode 100644\nnew mode 100755\n")
+	if len(got) != 1 || strings.Contains(got["x"], "old mode") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	got, err := parseVars([]string{"A=1", "B=x=y", "C="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]string{{"key": "A", "value": "1"}, {"key": "B", "value": "x=y"}, {"key": "C", "value": ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseVars = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"A", "=1"} {
+		if _, err := parseVars([]string{bad}); err == nil {
+			t.Errorf("parseVars(%q): want error", bad)
+		}
+	}
+}
+
+// TestPipelineWaitUsageExitCode runs pipeline-wait in a subprocess: a
+// mistyped flag or a bad variable must exit with exitToolError, not 2
+// (timeout) or 1 (pipeline failed).
+func TestPipelineWaitUsageExitCode(t *testing.T) {
+	if args := os.Getenv("GITLAB_NAVIGATOR_TEST_ARGS"); args != "" {
+		cmd := strings.Fields(args)
+		c := &apiClient{baseURL: "http://127.0.0.1:0"}
+		if cmd[0] == "pipeline-run" {
+			cmdPipelineRun(c, cmd[1:])
+		} else {
+			cmdPipelineWait(c, cmd[1:])
+		}
+		return
+	}
+	for _, args := range []string{
+		"pipeline-wait g/p 1 --timout 1s",
+		"pipeline-wait g/p",
+		"pipeline-run g/p main --wait -v NOEQUALS",
+		"pipeline-run g/p main --wiat",
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestPipelineWaitUsageExitCode$")
+		cmd.Env = append(os.Environ(), "GITLAB_NAVIGATOR_TEST_ARGS="+args)
+		err := cmd.Run()
+		var ee *exec.ExitError
+		if !errors.As(err, &ee) || ee.ExitCode() != exitToolError {
+			t.Errorf("%s: err = %v, want exit %d", args, err, exitToolError)
+		}
+	}
+}
+
+type zipEntry struct {
+	name, body string
+	mode       os.FileMode
+}
+
+func writeTestZip(t *testing.T, path string, entries []zipEntry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		h.SetMode(e.mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func TestExtractZipRefusesEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []zipEntry
+	}{
+		{"parent traversal", []zipEntry{{"ok.txt", "ok", 0o644}, {"../evil", "pwned", 0o644}}},
+		{"nested traversal", []zipEntry{{"a/../../evil", "pwned", 0o644}}},
+		{"absolute path", []zipEntry{{"ok.txt", "ok", 0o644}, {"/tmp/evil", "pwned", 0o644}}},
+	}
+	for _, tt := range tests {
+		base := t.TempDir()
+		dir := filepath.Join(base, "out")
+		archive := filepath.Join(base, "a.zip")
+		writeTestZip(t, archive, tt.entries)
+		if _, err := extractZip(archive, dir); err == nil || !strings.Contains(err.Error(), "unsafe path") {
+			t.Errorf("%s: err = %v, want unsafe path", tt.name, err)
+		}
+		// Validation happens before any write, so nothing exists at all.
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s: %s was created", tt.name, dir)
+		}
+		if _, err := os.Stat(filepath.Join(base, "evil")); !os.IsNotExist(err) {
+			t.Errorf("%s: wrote outside dir", tt.name)
+		}
+	}
+}
+
+func TestExtractZipSkipsSymlinks(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "out")
+	archive := filepath.Join(base, "a.zip")
+	writeTestZip(t, archive, []zipEntry{
+		{"link", "../outside", os.ModeSymlink | 0o777},
+		{"sub/", "", os.ModeDir | 0o755},
+		{"sub/ok.txt", "ok", 0o644},
+	})
+	n, err := extractZip(archive, dir)
+	if err != nil || n != 1 {
+		t.Fatalf("extractZip = %d, %v; want 1 file", n, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "link")); !os.IsNotExist(err) {
+		t.Errorf("symlink entry was extracted")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "sub", "ok.txt")); err != nil || string(data) != "ok" {
+		t.Errorf("sub/ok.txt = %q, %v", data, err)
+	}
+}
+
+func TestNewestTagBefore(t *testing.T) {
+	tag := func(name, date string) any {
+		return map[string]any{"name": name, "commit": map[string]any{"committed_date": date}}
+	}
+	tags := []any{
+		tag("v1.2.0", "2025-03-01T00:00:00Z"),
+		tag("v1.0.0", "2025-01-01T00:00:00Z"),
+		tag("v1.1.0", "2025-02-01T00:00:00Z"),
+		tag("v1.1.0-alias", "2025-02-01T00:00:00Z"),
+		tag("broken", ""),
+	}
+	at := func(s string) time.Time {
+		ts, _ := time.Parse(time.RFC3339, s)
+		return ts
+	}
+	tests := []struct {
+		name, tag string
+		before    time.Time
+		want      string
+	}{
+		{"new tag takes the newest", "v1.3.0", time.Time{}, "v1.2.0"},
+		{"backfill picks the release before", "v1.1.0", at("2025-02-01T00:00:00Z"), "v1.0.0"},
+		{"latest tag", "v1.2.0", at("2025-03-01T00:00:00Z"), "v1.1.0"},
+		{"first tag has none", "v1.0.0", at("2025-01-01T00:00:00Z"), ""},
+	}
+	for _, tt := range tests {
+		if got := newestTagBefore(tags, "name", tt.tag, tt.before); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildReleaseNotes(t *testing.T) {
+	mr := func(iid, title, author string, labels ...any) map[string]any {
+		return map[string]any{
+			"iid": iid, "title": title, "web_url": "https://gl/mr/" + iid,
+			"labels": labels, "author": map[string]any{"username": author},
+			"merge_commit_sha": "m" + iid,
+		}
+	}
+	mrs := []map[string]any{
+		mr("1", "Add CSV export", "alice", "feature"),
+		mr("2", "fix(api): handle empty body", "bob"),
+		mr("3", "feat!: drop v1 endpoints", "alice"),
+		mr("4", "Bump deps", "carol", "type::chore"),
+		mr("5", "Tidy things up", "dave"),
+	}
+	commits := []map[string]any{
+		{"id": "m1", "short_id": "m1", "title": "Merge branch 'csv' into 'main'", "parent_ids": []any{"a", "b"}},
+		{"id": "c1", "short_id": "c1", "title": "fix: typo in help", "author_name": "Erin"},
+		{"id": "c2", "short_id": "c2", "title": "wip", "author_name": "Frank"},
+		{"id": "c3", "short_id": "c3", "title": "refactor: split client", "message": "refactor: split client\n\nBREAKING CHANGE: New() takes options", "author_name": "Erin"},
+	}
+	got := buildReleaseNotes("v2.0.0", "v1.9.0", mrs, commits)
+	_, body, _ := strings.Cut(got, "\n\n")
+	want := `Changes since v1.9.0.
+
+### Breaking changes
+
+- drop v1 endpoints ([!3](https://gl/mr/3)) — @alice
+- split client (c3) — Erin
+
+### Features
+
+- Add CSV export ([!1](https://gl/mr/1)) — @alice
+
+### Bug fixes
+
+- handle empty body ([!2](https://gl/mr/2)) — @bob
+- typo in help (c1) — Erin
+
+### Maintenance
+
+- Bump deps ([!4](https://gl/mr/4)) — @carol
+
+### Other
+
+- Tidy things up ([!5](https://gl/mr/5)) — @dave
+
+### Contributors
+
+@alice, @bob, @carol, @dave, Erin
+`
+	if !strings.HasPrefix(got, "## v2.0.0 (") || body != want {
+		t.Errorf("notes:\n%s\nwant body:\n%s", got, want)
+	}
+
+	// Without MRs every non-merge commit becomes a note.
+	if got := buildReleaseNotes("v0.1.0", "", nil, commits); !strings.Contains(got, "- wip (c2) — Frank") || strings.Contains(got, "Changes since") {
+		t.Errorf("commit-only notes:\n%s", got)
+	}
+	if got := buildReleaseNotes("v0.1.0", "v0.0.9", nil, nil); !strings.HasSuffix(got, "No changes.\n") {
+		t.Errorf("empty notes:\n%s", got)
+	}
+}
+
+func TestParseYAMLSubset(t *testing.T) {
+	src := `---
+# audit policy
+default_branch_protected: true
+allow_force_push: no
+min_approvals: 2
+visibility: [private, internal]   # inline list
+note: "a # not a comment"
+owner: it's ops # trailing
+exclude:
+  - sandbox/*
+  - 'archive/*'
+severity:
+  container_scanning: MED
+  min_approvals: high
+empty:
+`
+	got, err := parseYAMLSubset(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"default_branch_protected": true,
+		"allow_force_push":         false,
+		"min_approvals":            float64(2),
+		"visibility":               []any{"private", "internal"},
+		"note":                     "a # not a comment",
+		"owner":                    "it's ops",
+		"exclude":                  []any{"sandbox/*", "archive/*"},
+		"severity":                 map[string]any{"container_scanning": "MED", "min_approvals": "high"},
+		"empty":                    nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAMLSubset =\n%#v\nwant\n%#v", got, want)
+	}
+
+	for _, bad := range []string{
+		"just text",
+		"  indented: first",
+		"list:\n  - a\n  k: v",
+		"map:\n  k: v\n  - a",
+		"key:\n  neither",
+	} {
+		if _, err := parseYAMLSubset(bad); err == nil {
+			t.Errorf("parseYAMLSubset(%q): want error", bad)
+		}
+	}
+}
+
+func TestLoadPolicySeverities(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	p, err := loadPolicy(write("p.yaml", "min_approvals: 1\nseverity:\n  container_scanning: HIGH\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.severities["container_scanning"] != sevHigh || p.severities["min_approvals"] != sevMed {
+		t.Errorf("severities = %v", p.severities)
+	}
+	if defaultAuditSeverity["container_scanning"] != sevLow {
+		t.Errorf("loadPolicy changed the defaults: %v", defaultAuditSeverity)
+	}
+	if _, err := loadPolicy(write("typo.json", `{"min_aprovals": 1}`)); err == nil {
+		t.Error("unknown key: want error")
+	}
+	if _, err := loadPolicy(write("sev.yaml", "severity:\n  nope: HIGH\n")); err == nil {
+		t.Error("severity for unknown check: want error")
+	}
+}
+
+func TestAuditProjectCheckErrors(t *testing.T) {
+	status := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for suffix, code := range status {
+			if strings.HasSuffix(r.URL.Path, suffix) {
+				http.Error(w, `{"message":"nope"}`, code)
+				return
+			}
+		}
+		http.Error(w, `{"message":"404 Not Found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+	c := &apiClient{baseURL: srv.URL}
+	yes, two := true, 2
+	policy := &auditPolicy{DefaultBranchProtected: &yes, MinApprovals: &two, severities: defaultAuditSeverity}
+	pm := map[string]any{"id": float64(1), "path_with_namespace": "g/p", "default_branch": "main"}
+
+	run := func(codes map[string]int) map[string]severity {
+		status = codes
+		var rep report
+		auditProject(c, pm, policy, &rep)
+		got := map[string]severity{}
+		for _, f := range rep.findings {
+			got[f.category+" "+strings.SplitN(f.message, ":", 2)[0]] = f.sev
+		}
+		return got
+	}
+
+	// No permission: both checks become HIGH errors, never "unverified".
+	got := run(map[string]int{"/protected_branches/main": 403, "/approval_rules": 403})
+	want := map[string]severity{"error default_branch_protected": sevHigh, "error min_approvals": sevHigh}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("403s: findings = %v, want %v", got, want)
+	}
+
+	// Tier without approval rules or the legacy endpoint: unverified.
+	got = run(map[string]int{"/protected_branches/main": 401})
+	want = map[string]severity{"error default_branch_protected": sevHigh, "unverified min_approvals": sevLow}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("404s: findings = %v, want %v", got, want)
+	}
+}
+
+func TestDaysRemaining(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC)
+	for due, want := range map[string]string{
+		"2026-10-21": "3 day(s) remaining",
+		"2026-10-19": "1 day(s) remaining",
+		"2026-10-18": "due today",
+		"2026-10-10": "overdue by 8 day(s)",
+		"":           "no due date",
+		"soon":       "no due date",
+	} {
+		if got := daysRemaining(due, now); got != want {
+			t.Errorf("daysRemaining(%q) = %q, want %q", due, got, want)
+		}
+	}
+}
+
+func TestBurnup(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	issues := []map[string]any{
+		{"weight": float64(3), "created_at": "2026-10-01T09:00:00Z", "closed_at": "2026-10-02T17:00:00Z"},
+		{"weight": float64(1), "created_at": "2026-10-03T00:00:00Z"},                // joins on the 3rd, not the 2nd
+		{"created_at": "2026-10-01T10:00:00Z", "closed_at": "2026-10-03T00:00:00Z"}, // unweighted
+	}
+	got := burnup(issues, day(1), day(3))
+	want := []burnupPoint{
+		{date: day(1), scope: 2, closed: 0, scopeWeight: 3, closedWeight: 0},
+		{date: day(2), scope: 2, closed: 1, scopeWeight: 3, closedWeight: 3},
+		{date: day(3), scope: 3, closed: 2, scopeWeight: 4, closedWeight: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("burnup =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Longer than four weeks: weekly points, always ending on the end date.
+	weekly := burnup(nil, day(1), day(31))
+	var dates []string
+	for _, p := range weekly {
+		dates = append(dates, p.date.Format("01-02"))
+	}
+	if want := "10-01 10-08 10-15 10-22 10-29 10-31"; strings.Join(dates, " ") != want {
+		t.Errorf("weekly dates = %v, want %s", dates, want)
+	}
+}
+
+func TestMarkRollbacks(t *testing.T) {
+	for _, tc := range []struct {
+		shas string
+		want string // one flag per deployment
+	}{
+		{"a b a", "..R"},
+		{"a a a", "..."}, // redeploying the same SHA is a retry
+		{"a b c", "..."},
+		{"a b b a", "...R"},
+		{"a b a b", "..RR"},
+		{"a  a", "..R"}, // unknown SHA in between
+	} {
+		var deploys []*doraDeploy
+		for _, sha := range strings.Split(tc.shas, " ") {
+			deploys = append(deploys, &doraDeploy{sha: sha})
+		}
+		markRollbacks(deploys)
+		var got strings.Builder
+		for _, d := range deploys {
+			if d.rollback {
+				got.WriteByte('R')
+			} else {
+				got.WriteByte('.')
+			}
+		}
+		if got.String() != tc.want {
+			t.Errorf("markRollbacks(%q) = %s, want %s", tc.shas, got.String(), tc.want)
+		}
+	}
+}
+
+func TestMarkFailures(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2026, 10, 1, h, 0, 0, 0, time.UTC) }
+	deploys := []*doraDeploy{
+		{at: at(0), hotfix: true}, // nothing before it to blame
+		{at: at(1)},
+		{at: at(3), hotfix: true}, // hotfix label: previous deploy failed
+		{at: at(4)},
+		{at: at(9), rollback: true},
+		{at: at(10)},
+	}
+	markFailures(deploys)
+	var failed []int
+	for i, d := range deploys {
+		if d.failed {
+			failed = append(failed, i)
+		}
+	}
+	if !reflect.DeepEqual(failed, []int{1, 3}) {
+		t.Fatalf("failed deployments = %v, want [1 3]", failed)
+	}
+	if deploys[1].restore != 2*time.Hour || deploys[3].restore != 5*time.Hour {
+		t.Errorf("restore = %v, %v; want 2h, 5h", deploys[1].restore, deploys[3].restore)
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	plus3 := time.FixedZone("+03", 3*60*60)
+	for in, want := range map[time.Time]string{
+		time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC):   "2026-10-12", // Monday
+		time.Date(2026, 10, 14, 13, 5, 0, 0, time.UTC):  "2026-10-12",
+		time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC): "2026-10-12", // Sunday
+		time.Date(2026, 10, 19, 1, 0, 0, 0, plus3):      "2026-10-12", // Sunday in UTC
+		time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC):    "2025-12-29", // across a year
+	} {
+		got := weekStart(in)
+		if got.Format("2006-01-02") != want || got.Location() != time.UTC || got.Hour() != 0 {
+			t.Errorf("weekStart(%v) = %v, want %s 00:00 UTC", in, got, want)
+		}
+	}
+}
+
+func TestMedianDuration(t *testing.T) {
+	for _, tc := range []struct {
+		in   []time.Duration
+		want time.Duration
+	}{
+		{nil, 0},
+		{[]time.Duration{5}, 5},
+		{[]time.Duration{9, 1, 5}, 5},
+		{[]time.Duration{4, 1, 3, 2}, 2}, // (2+3)/2, truncated
+		{[]time.Duration{time.Hour, 3 * time.Hour}, 2 * time.Hour},
+	} {
+		in := append([]time.Duration(nil), tc.in...)
+		if got := medianDuration(in); got != tc.want {
+			t.Errorf("medianDuration(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+		if !reflect.DeepEqual(in, tc.in) {
+			t.Errorf("medianDuration reordered its input: %v", in)
+		}
+	}
+}
@@ -2,17 +2,31 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // ── Netrc parsing ───────────────────────────────────────────
@@ -150,6 +164,17 @@ type apiClient struct {
 	password string
 }
 
+// apiError is returned for HTTP error responses so callers can inspect the
+// status and JSON body (e.g. JQL errorMessages).
+type apiError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("API returned HTTP %d: %s", e.StatusCode, string(e.Body))
+}
+
 func newClient(host string, entry netrcEntry) *apiClient {
 	return &apiClient{
 		baseURL:  "https://" + host,
@@ -173,7 +198,7 @@ func (c *apiClient) doGet(fullURL string) (json.RawMessage, error) {
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API returned HTTP %d: %s", resp.StatusCode, string(body))
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: body}
 	}
 	return json.RawMessage(body), nil
 }
@@ -202,7 +227,7 @@ func (c *apiClient) doPost(fullURL string, body []byte) (json.RawMessage, error)
 	defer resp.Body.Close()
 	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API returned HTTP %d: %s", resp.StatusCode, string(respBody))
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: respBody}
 	}
 	return json.RawMessage(respBody), nil
 }
@@ -227,7 +252,7 @@ func (c *apiClient) doPut(fullURL string, body []byte) (json.RawMessage, error)
 	defer resp.Body.Close()
 	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("API returned HTTP %d: %s", resp.StatusCode, string(respBody))
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: respBody}
 	}
 	return json.RawMessage(respBody), nil
 }
@@ -236,6 +261,30 @@ func (c *apiClient) put(endpoint string, body []byte) (json.RawMessage, error) {
 	return c.doPut(c.baseURL+"/rest/api/2"+endpoint, body)
 }
 
+func (c *apiClient) doDelete(fullURL string) error {
+	req, err := http.NewRequest("DELETE", fullURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.password)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return &apiError{StatusCode: resp.StatusCode, Body: respBody}
+	}
+	return nil
+}
+
+func (c *apiClient) delete(endpoint string) error {
+	return c.doDelete(c.baseURL + "/rest/api/2" + endpoint)
+}
+
 func (c *apiClient) getAgile(endpoint string, params url.Values) (json.RawMessage, error) {
 	u := c.baseURL + "/rest/agile/1.0" + endpoint
 	if len(params) > 0 {
@@ -489,12 +538,15 @@ func cmdWatchChanges(c *apiClient, args []string) {
 
 func cmdSearch(c *apiClient, args []string) {
 	if len(args) == 0 {
-		die("Usage: search <JQL query> [limit]")
+		die("Usage: search <JQL query | @alias [key=value...]> [limit]")
+	}
+	jql, rest, err := resolveJQLArgs(args)
+	if err != nil {
+		die("%s", err)
 	}
-	jql := args[0]
 	limit := "20"
-	if len(args) > 1 {
-		limit = args[1]
+	if len(rest) > 0 {
+		limit = rest[0]
 	}
 	params := url.Values{
 		"jql":        {jql},
@@ -503,7 +555,7 @@ func cmdSearch(c *apiClient, args []string) {
 	}
 	data, err := c.get("/search", params)
 	if err != nil {
-		die("%s", err)
+		die("%s", explainJQLError(jql, err))
 	}
 	var m map[string]any
 	json.Unmarshal(data, &m)
@@ -709,9 +761,15 @@ func cmdComments(c *apiClient, args []string) {
 			continue
 		}
 		author := jsonMap(cm, "author")
-		fmt.Printf("%s (%s):\n%s\n---\n\n",
+		restricted := ""
+		if vis := jsonMap(cm, "visibility"); vis != nil {
+			restricted = fmt.Sprintf(" [restricted to %s: %s]", jsonStr(vis, "type"), jsonStr(vis, "value"))
+		}
+		fmt.Printf("[%s] %s (%s)%s:\n%s\n---\n\n",
+			jsonStr(cm, "id"),
 			strOr(jsonStr(author, "displayName"), "unknown"),
 			jsonStr(cm, "created"),
+			restricted,
 			jsonStr(cm, "body"))
 	}
 }
@@ -1031,245 +1089,2296 @@ func cmdSprintIssues(c *apiClient, args []string) {
 	}
 }
 
-// ── Help ────────────────────────────────────────────────────
+// ── Saved filters and JQL aliases ───────────────────────────
 
-// ── Write commands ──────────────────────────────────────────
+var jqlPosRe = regexp.MustCompile(`line (\d+), character (\d+)`)
 
-// readBody returns the body text: --body wins, then --body-file, then stdin
-// when --body-stdin is set. Exits with a clear error if more than one is set
-// or none is set (callers that accept "no body" should validate before calling).
-func readBody(flagVal, fileVal string, stdinFlag bool) string {
-	set := 0
-	if flagVal != "" {
-		set++
-	}
-	if fileVal != "" {
-		set++
-	}
-	if stdinFlag {
-		set++
-	}
-	if set > 1 {
-		die("pass at most one of --body, --body-file, --body-stdin")
+// validateJQL asks the server to parse jql without returning issues. On a
+// syntax error the returned error quotes the query with a caret under each
+// position Jira reports.
+func validateJQL(c *apiClient, jql string) error {
+	_, err := c.get("/search", url.Values{
+		"jql": {jql}, "maxResults": {"0"}, "validateQuery": {"strict"},
+	})
+	return explainJQLError(jql, err)
+}
+
+// explainJQLError rewrites a 400 from a JQL endpoint into the server's
+// error messages plus a caret marker; other errors pass through unchanged.
+func explainJQLError(jql string, err error) error {
+	var ae *apiError
+	if !errors.As(err, &ae) || ae.StatusCode != http.StatusBadRequest {
+		return err
 	}
-	switch {
-	case flagVal != "":
-		return flagVal
-	case fileVal != "":
-		data, err := os.ReadFile(fileVal)
-		if err != nil {
-			die("read %s: %v", fileVal, err)
+	var m map[string]any
+	if json.Unmarshal(ae.Body, &m) != nil {
+		return err
+	}
+	msgs := toStringSlice(jsonArr(m, "errorMessages"))
+	if len(msgs) == 0 {
+		return err
+	}
+	lines := strings.Split(jql, "\n")
+	var b strings.Builder
+	b.WriteString("invalid JQL:")
+	for _, msg := range msgs {
+		b.WriteString("\n  " + msg)
+		pos := jqlPosRe.FindStringSubmatch(msg)
+		if pos == nil {
+			continue
 		}
-		return string(data)
-	case stdinFlag:
-		data, err := io.ReadAll(os.Stdin)
-		if err != nil {
-			die("read stdin: %v", err)
+		line, _ := strconv.Atoi(pos[1])
+		col, _ := strconv.Atoi(pos[2])
+		if line < 1 || line > len(lines) {
+			continue
 		}
-		return string(data)
-	default:
-		return ""
+		// Jira counts characters from 1.
+		text := lines[line-1]
+		if col < 1 || col > len(text)+1 {
+			col = len(text) + 1
+		}
+		fmt.Fprintf(&b, "\n    %s\n    %s^", text, strings.Repeat(" ", col-1))
 	}
+	return errors.New(b.String())
 }
 
-// cmdCreateIssue creates a new Jira issue and prints the new key.
-//
-//	<host> create-issue --project SR --type Story --summary "..."
-//	                    [--epic SR-1416] [--assignee user]
-//	                    [--priority Medium] [--labels a,b,c]
-//	                    [--desc "..."] [--desc-file path] [--desc-stdin]
-//	                    [--epic-field customfield_10101]
-func cmdCreateIssue(c *apiClient, args []string) {
-	fs := flag.NewFlagSet("create-issue", flag.ExitOnError)
-	project := fs.String("project", "", "project key (required)")
-	issueType := fs.String("type", "Story", "issue type name (Story, Task, Bug, ...)")
-	summary := fs.String("summary", "", "issue summary (required)")
-	epic := fs.String("epic", "", "epic link key (e.g. SR-1416) — uses --epic-field")
-	epicField := fs.String("epic-field", "customfield_10101", "custom field ID used for epic link on this instance")
-	assignee := fs.String("assignee", "", "assignee username (default: current user)")
-	priority := fs.String("priority", "", "priority name (optional)")
-	labels := fs.String("labels", "", "comma-separated labels (optional)")
-	desc := fs.String("desc", "", "description text")
-	descFile := fs.String("desc-file", "", "read description from file")
-	descStdin := fs.Bool("desc-stdin", false, "read description from stdin")
-	_ = fs.Parse(args)
-
-	if *project == "" || *summary == "" {
-		die("create-issue: --project and --summary are required")
+func aliasFilePath() string {
+	if p := os.Getenv("JIRA_NAVIGATOR_ALIASES"); p != "" {
+		return p
 	}
+	return configPath("aliases.json")
+}
 
-	fields := map[string]any{
-		"project":   map[string]string{"key": *project},
-		"issuetype": map[string]string{"name": *issueType},
-		"summary":   *summary,
+func loadAliases() (map[string]string, error) {
+	aliases := map[string]string{}
+	data, err := os.ReadFile(aliasFilePath())
+	if os.IsNotExist(err) {
+		return aliases, nil
 	}
-	if body := readBody(*desc, *descFile, *descStdin); body != "" {
-		fields["description"] = body
+	if err != nil {
+		return nil, err
 	}
-	if *epic != "" {
-		fields[*epicField] = *epic
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", aliasFilePath(), err)
 	}
-	if *assignee != "" {
-		fields["assignee"] = map[string]string{"name": *assignee}
+	return aliases, nil
+}
+
+func saveAliases(aliases map[string]string) error {
+	p := aliasFilePath()
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
 	}
-	if *priority != "" {
-		fields["priority"] = map[string]string{"name": *priority}
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return err
 	}
-	if *labels != "" {
-		var lbls []string
-		for _, s := range strings.Split(*labels, ",") {
-			if t := strings.TrimSpace(s); t != "" {
-				lbls = append(lbls, t)
-			}
+	return os.WriteFile(p, append(data, '\n'), 0o600)
+}
+
+// aliasParamRe matches {name} and {name=default} placeholders.
+var aliasParamRe = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_-]*)(?:=([^}]*))?\}`)
+
+// expandAlias substitutes key=value params into an alias template. Params
+// without a value fall back to their {name=default}; any left unset are
+// reported together.
+func expandAlias(name, tmpl string, params map[string]string) (string, error) {
+	var missing []string
+	out := aliasParamRe.ReplaceAllStringFunc(tmpl, func(ph string) string {
+		sub := aliasParamRe.FindStringSubmatch(ph)
+		if v, ok := params[sub[1]]; ok {
+			return v
 		}
-		if len(lbls) > 0 {
-			fields["labels"] = lbls
+		if strings.Contains(ph, "=") {
+			return sub[2]
 		}
+		missing = append(missing, sub[1])
+		return ph
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("alias @%s needs %s", name, strings.Join(missing, "=..., ")+"=...")
 	}
+	return out, nil
+}
 
-	payload, err := json.Marshal(map[string]any{"fields": fields})
-	if err != nil {
-		die("marshal payload: %v", err)
+// resolveJQLArgs expands `@alias key=val ...` into JQL and returns the
+// remaining positional args. Plain JQL passes through untouched.
+func resolveJQLArgs(args []string) (string, []string, error) {
+	if !strings.HasPrefix(args[0], "@") {
+		return args[0], args[1:], nil
 	}
-
-	resp, err := c.post("/issue", payload)
+	name := args[0][1:]
+	aliases, err := loadAliases()
 	if err != nil {
-		die("create issue: %v", err)
+		return "", nil, err
 	}
-	var out map[string]any
-	if err := json.Unmarshal(resp, &out); err != nil {
-		die("parse response: %v", err)
+	tmpl, ok := aliases[name]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown alias @%s (see `aliases`)", name)
 	}
-	key := jsonStr(out, "key")
-	if key == "" {
-		die("response missing key: %s", string(resp))
+	params := map[string]string{}
+	var rest []string
+	for _, a := range args[1:] {
+		if k, v, ok := strings.Cut(a, "="); ok && !strings.HasPrefix(a, "-") {
+			params[k] = v
+			continue
+		}
+		rest = append(rest, a)
 	}
-	fmt.Println(key)
+	jql, err := expandAlias(name, tmpl, params)
+	return jql, rest, err
 }
 
-// cmdComment posts a comment on an issue.
-//
-//	<host> comment <key> --body "..."
-//	<host> comment <key> --body-file path
-//	<host> comment <key> --body-stdin
-func cmdComment(c *apiClient, args []string) {
-	if len(args) < 1 {
-		die("comment: <key> is required")
-	}
-	key := args[0]
-	fs := flag.NewFlagSet("comment", flag.ExitOnError)
-	body := fs.String("body", "", "comment body")
-	bodyFile := fs.String("body-file", "", "read comment body from file")
-	bodyStdin := fs.Bool("body-stdin", false, "read comment body from stdin")
-	_ = fs.Parse(args[1:])
-
-	text := readBody(*body, *bodyFile, *bodyStdin)
-	if strings.TrimSpace(text) == "" {
-		die("comment: body is empty (use --body, --body-file, or --body-stdin)")
-	}
-
-	payload, err := json.Marshal(map[string]any{"body": text})
+func cmdAliases() {
+	aliases, err := loadAliases()
 	if err != nil {
-		die("marshal payload: %v", err)
+		die("%s", err)
 	}
-	resp, err := c.post("/issue/"+url.PathEscape(key)+"/comment", payload)
-	if err != nil {
-		die("post comment: %v", err)
+	if len(aliases) == 0 {
+		fmt.Printf("No aliases defined (%s).\n", aliasFilePath())
+		return
 	}
-	var out map[string]any
-	if err := json.Unmarshal(resp, &out); err != nil {
-		die("parse response: %v", err)
+	names := make([]string, 0, len(aliases))
+	for n := range aliases {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	fmt.Printf("JQL aliases (%s):\n\n", aliasFilePath())
+	for _, n := range names {
+		fmt.Printf("@%s\n  %s\n\n", n, aliases[n])
 	}
-	fmt.Printf("comment posted to %s: id=%s\n", key, jsonStr(out, "id"))
 }
 
-// cmdEditComment replaces the body of an existing comment.
+// cmdAliasSet stores a JQL alias after the server accepts it. Templates
+// with parameters are validated with their defaults filled in; if some
+// parameters have no default, validation happens at use time instead.
 //
-//	<host> edit-comment <key> <comment-id> [--body ... | --body-file path | --body-stdin]
-//
-// Note: this Jira instance (and many Server/DC installs with richer auto-
-// linking) treats comment bodies as plain text with only issue-key and URL
-// auto-linking. Wiki markup like h3., {{code}}, || tables || is NOT
-// interpreted — it will render literally. Use plain prose + blank lines.
-func cmdEditComment(c *apiClient, args []string) {
+//	<host> alias-set <name> '<JQL with {param} or {param=default}>'
+func cmdAliasSet(c *apiClient, args []string) {
 	if len(args) < 2 {
-		die("edit-comment: <key> <comment-id> required")
+		die("Usage: alias-set <name> '<JQL>'")
 	}
-	key := args[0]
-	commentID := args[1]
-
-	fs := flag.NewFlagSet("edit-comment", flag.ExitOnError)
-	body := fs.String("body", "", "new comment body")
-	bodyFile := fs.String("body-file", "", "read new comment body from file")
-	bodyStdin := fs.Bool("body-stdin", false, "read new comment body from stdin")
-	_ = fs.Parse(args[2:])
-
-	text := readBody(*body, *bodyFile, *bodyStdin)
-	if strings.TrimSpace(text) == "" {
-		die("edit-comment: body is empty (use --body, --body-file, or --body-stdin)")
+	name := strings.TrimPrefix(args[0], "@")
+	tmpl := args[1]
+	if jql, err := expandAlias(name, tmpl, nil); err == nil {
+		if err := validateJQL(c, jql); err != nil {
+			die("%s", err)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "note: %v; skipping server-side validation until it is used\n", err)
 	}
-
-	payload, err := json.Marshal(map[string]any{"body": text})
+	aliases, err := loadAliases()
 	if err != nil {
-		die("marshal payload: %v", err)
+		die("%s", err)
 	}
-	if _, err := c.put("/issue/"+url.PathEscape(key)+"/comment/"+url.PathEscape(commentID), payload); err != nil {
-		die("edit comment: %v", err)
+	aliases[name] = tmpl
+	if err := saveAliases(aliases); err != nil {
+		die("save aliases: %v", err)
 	}
-	fmt.Printf("comment %s on %s updated\n", commentID, key)
+	fmt.Printf("alias @%s saved\n", name)
 }
 
-// cmdTransition moves an issue to a new status.
-//
-//	<host> transition <key> <transition-id>
-//	<host> transition <key> <transition-id> --comment "..."
-//
-// Use `transitions <key>` first to list the available IDs for that issue.
-func cmdTransition(c *apiClient, args []string) {
-	if len(args) < 2 {
-		die("transition: <key> <transition-id> required (use `transitions <key>` to list)")
+func cmdAliasRm(args []string) {
+	if len(args) == 0 {
+		die("Usage: alias-rm <name>")
 	}
-	key := args[0]
-	transitionID := args[1]
-
-	fs := flag.NewFlagSet("transition", flag.ExitOnError)
-	comment := fs.String("comment", "", "optional comment posted with the transition")
-	_ = fs.Parse(args[2:])
+	name := strings.TrimPrefix(args[0], "@")
+	aliases, err := loadAliases()
+	if err != nil {
+		die("%s", err)
+	}
+	if _, ok := aliases[name]; !ok {
+		die("unknown alias @%s", name)
+	}
+	delete(aliases, name)
+	if err := saveAliases(aliases); err != nil {
+		die("save aliases: %v", err)
+	}
+	fmt.Printf("alias @%s removed\n", name)
+}
 
-	payload := map[string]any{
-		"transition": map[string]string{"id": transitionID},
+func cmdFilter(c *apiClient, args []string) {
+	if len(args) == 0 {
+		die("Usage: filter <filter-id> [limit]")
 	}
-	if *comment != "" {
-		payload["update"] = map[string]any{
-			"comment": []map[string]any{
-				{"add": map[string]string{"body": *comment}},
-			},
-		}
+	limit := "20"
+	if len(args) > 1 {
+		limit = args[1]
 	}
-	body, err := json.Marshal(payload)
+	data, err := c.get("/filter/"+url.PathEscape(args[0]), nil)
 	if err != nil {
-		die("marshal payload: %v", err)
+		die("%s", err)
 	}
-	if _, err := c.post("/issue/"+url.PathEscape(key)+"/transitions", body); err != nil {
-		die("transition: %v", err)
+	var f map[string]any
+	json.Unmarshal(data, &f)
+	jql := jsonStr(f, "jql")
+	data, err = c.get("/search", url.Values{
+		"jql":        {jql},
+		"maxResults": {limit},
+		"fields":     {"summary,status,assignee,priority,issuetype,project,updated"},
+	})
+	if err != nil {
+		die("%s", explainJQLError(jql, err))
 	}
-	fmt.Printf("%s transitioned via id=%s\n", key, transitionID)
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("Filter [%s] %s (%s total)\n  JQL: %s\n\n", jsonStr(f, "id"), jsonStr(f, "name"), jsonStr(m, "total"), jql)
+	printIssueList(m)
 }
 
-func printHelp() {
-	fmt.Println(`Usage: jira-navigator <host> <command> [args...]
+// parseSharePermissions converts --share specs into Jira sharePermissions:
+// "global", "group:<name>" or "project:<KEY>" (project keys are resolved
+// to IDs, which the filter API requires).
+func parseSharePermissions(c *apiClient, specs []string) ([]map[string]any, error) {
+	var perms []map[string]any
+	for _, spec := range specs {
+		kind, value, _ := strings.Cut(spec, ":")
+		switch strings.ToLower(kind) {
+		case "global":
+			perms = append(perms, map[string]any{"type": "global"})
+		case "group":
+			if value == "" {
+				return nil, fmt.Errorf("--share group:<name> needs a group name")
+			}
+			perms = append(perms, map[string]any{"type": "group", "group": map[string]string{"name": value}})
+		case "project":
+			if value == "" {
+				return nil, fmt.Errorf("--share project:<KEY> needs a project key")
+			}
+			data, err := c.get("/project/"+url.PathEscape(value), nil)
+			if err != nil {
+				return nil, fmt.Errorf("look up project %s: %w", value, err)
+			}
+			var pm map[string]any
+			json.Unmarshal(data, &pm)
+			perms = append(perms, map[string]any{"type": "project", "project": map[string]string{"id": jsonStr(pm, "id")}})
+		default:
+			return nil, fmt.Errorf("invalid --share %q (want global, group:<name> or project:<KEY>)", spec)
+		}
+	}
+	return perms, nil
+}
 
-Discovery:
-  discover [substring]                  Find Jira hosts in ~/.netrc
+// stringList is a repeatable string flag.
+type stringList []string
 
-Commands (first arg is hostname or substring matching ~/.netrc):
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+// cmdFilterCreate saves a new filter after validating its JQL.
+//
+//	<host> filter-create --name "..." --jql '...' [--description ...]
+//	                     [--share global|group:G|project:KEY]... [--favourite]
+func cmdFilterCreate(c *apiClient, args []string) {
+	fs := flag.NewFlagSet("filter-create", flag.ExitOnError)
+	name := fs.String("name", "", "filter name (required)")
+	jql := fs.String("jql", "", "filter JQL, or @alias (required)")
+	description := fs.String("description", "", "filter description")
+	favourite := fs.Bool("favourite", true, "mark as favourite")
+	var shares stringList
+	fs.Var(&shares, "share", "share with global, group:<name> or project:<KEY> (repeatable)")
+	_ = fs.Parse(args)
+
+	if *name == "" || *jql == "" {
+		die("filter-create: --name and --jql are required")
+	}
+	query, _, err := resolveJQLArgs([]string{*jql})
+	if err != nil {
+		die("%s", err)
+	}
+	if err := validateJQL(c, query); err != nil {
+		die("%s", err)
+	}
+	perms, err := parseSharePermissions(c, shares)
+	if err != nil {
+		die("%s", err)
+	}
+	payload := map[string]any{
+		"name":      *name,
+		"jql":       query,
+		"favourite": *favourite,
+	}
+	if *description != "" {
+		payload["description"] = *description
+	}
+	if len(perms) > 0 {
+		payload["sharePermissions"] = perms
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		die("marshal payload: %v", err)
+	}
+	resp, err := c.post("/filter", body)
+	if err != nil {
+		die("create filter: %v", err)
+	}
+	var out map[string]any
+	json.Unmarshal(resp, &out)
+	fmt.Printf("filter created: id=%s %s\n", jsonStr(out, "id"), jsonStr(out, "viewUrl"))
+}
+
+// cmdFilterUpdate changes name, JQL, description or sharing of a filter.
+// Unset flags keep their current values; --description "" clears the
+// description; --share replaces all shares.
+//
+//	<host> filter-update <id> [--name ...] [--jql ...] [--description ...] [--share ...]...
+func cmdFilterUpdate(c *apiClient, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		die("Usage: filter-update <filter-id> [--name ...] [--jql ...] [--description ...] [--share ...]")
+	}
+	id := args[0]
+	fs := flag.NewFlagSet("filter-update", flag.ExitOnError)
+	name := fs.String("name", "", "new filter name")
+	jql := fs.String("jql", "", "new JQL, or @alias")
+	description := fs.String("description", "", `new description ("" clears it)`)
+	var shares stringList
+	fs.Var(&shares, "share", "replace shares with global, group:<name> or project:<KEY> (repeatable)")
+	_ = fs.Parse(args[1:])
+	descriptionSet := false
+	fs.Visit(func(f *flag.Flag) { descriptionSet = descriptionSet || f.Name == "description" })
+
+	data, err := c.get("/filter/"+url.PathEscape(id), nil)
+	if err != nil {
+		die("%s", err)
+	}
+	var cur map[string]any
+	json.Unmarshal(data, &cur)
+	payload := map[string]any{
+		"name":        strOr(*name, jsonStr(cur, "name")),
+		"jql":         jsonStr(cur, "jql"),
+		"description": jsonStr(cur, "description"),
+	}
+	if descriptionSet {
+		payload["description"] = *description
+	}
+	if *jql != "" {
+		query, _, err := resolveJQLArgs([]string{*jql})
+		if err != nil {
+			die("%s", err)
+		}
+		if err := validateJQL(c, query); err != nil {
+			die("%s", err)
+		}
+		payload["jql"] = query
+	}
+	if len(shares) > 0 {
+		perms, err := parseSharePermissions(c, shares)
+		if err != nil {
+			die("%s", err)
+		}
+		payload["sharePermissions"] = perms
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		die("marshal payload: %v", err)
+	}
+	if _, err := c.put("/filter/"+url.PathEscape(id), body); err != nil {
+		die("update filter: %v", err)
+	}
+	fmt.Printf("filter %s updated\n", id)
+}
+
+// ── Pivot report ────────────────────────────────────────────
+
+// fieldAliases maps the short names people type to Jira field IDs.
+var fieldAliases = map[string]string{
+	"type":       "issuetype",
+	"fixversion": "fixVersions",
+	"component":  "components",
+	"label":      "labels",
+}
+
+// resolveFieldID accepts a field ID (status, customfield_10002) or a
+// display name ("Story Points") and returns the field ID.
+func resolveFieldID(name string, fields []any) (string, error) {
+	if id, ok := fieldAliases[strings.ToLower(name)]; ok {
+		return id, nil
+	}
+	for _, f := range fields {
+		fm := asMap(f)
+		if jsonStr(fm, "id") == name {
+			return name, nil
+		}
+	}
+	for _, f := range fields {
+		fm := asMap(f)
+		if strings.EqualFold(jsonStr(fm, "name"), name) {
+			return jsonStr(fm, "id"), nil
+		}
+	}
+	return "", fmt.Errorf("unknown field %q (use a field ID or display name)", name)
+}
+
+// fieldValues flattens a field value into the labels it should be grouped
+// under. Multi-valued fields (labels, components) yield one label each.
+func fieldValues(v any) []string {
+	switch t := v.(type) {
+	case nil:
+		return []string{"(none)"}
+	case string:
+		return []string{strOr(t, "(none)")}
+	case float64:
+		return []string{strconv.FormatFloat(t, 'f', -1, 64)}
+	case bool:
+		return []string{strconv.FormatBool(t)}
+	case map[string]any:
+		for _, k := range []string{"displayName", "name", "value", "key"} {
+			if s := jsonStr(t, k); s != "" {
+				return []string{s}
+			}
+		}
+		return []string{"(none)"}
+	case []any:
+		if len(t) == 0 {
+			return []string{"(none)"}
+		}
+		var out []string
+		for _, item := range t {
+			out = append(out, fieldValues(item)...)
+		}
+		return out
+	}
+	return []string{fmt.Sprint(v)}
+}
+
+// pivot accumulates per-issue values. An issue with several values in a
+// multi-valued field (labels, components) lands in several cells, but is
+// counted once in each row, column and grand total.
+type pivot struct {
+	cells     map[string]map[string]float64
+	rowTot    map[string]float64
+	colTot    map[string]float64
+	total     float64
+	seen      map[string]bool
+	fannedOut bool
+}
+
+func newPivot() *pivot {
+	return &pivot{
+		cells:  map[string]map[string]float64{},
+		rowTot: map[string]float64{},
+		colTot: map[string]float64{},
+		seen:   map[string]bool{},
+	}
+}
+
+// once reports whether key is new, recording it.
+func (p *pivot) once(key string) bool {
+	if p.seen[key] {
+		return false
+	}
+	p.seen[key] = true
+	return true
+}
+
+func (p *pivot) add(issue, row, col string, v float64) {
+	if !p.once("cell\x00" + issue + "\x00" + row + "\x00" + col) {
+		return
+	}
+	if p.cells[row] == nil {
+		p.cells[row] = map[string]float64{}
+	}
+	p.cells[row][col] += v
+	if p.once("row\x00" + issue + "\x00" + row) {
+		p.rowTot[row] += v
+	}
+	if p.once("col\x00" + issue + "\x00" + col) {
+		p.colTot[col] += v
+	}
+	if p.once("issue\x00" + issue) {
+		p.total += v
+	} else {
+		p.fannedOut = true
+	}
+}
+
+// rows orders by row total (largest first) so the busiest people lead the
+// standup table; columns are alphabetical.
+func (p *pivot) rows() []string {
+	var rs []string
+	for r := range p.rowTot {
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if p.rowTot[rs[i]] != p.rowTot[rs[j]] {
+			return p.rowTot[rs[i]] > p.rowTot[rs[j]]
+		}
+		return rs[i] < rs[j]
+	})
+	return rs
+}
+
+func (p *pivot) cols() []string {
+	var cs []string
+	for c := range p.colTot {
+		cs = append(cs, c)
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// table returns the pivot as a grid including header and totals.
+func (p *pivot) table(rowLabel string) [][]string {
+	fmtNum := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	cols := p.cols()
+	header := append([]string{rowLabel}, cols...)
+	grid := [][]string{append(header, "Total")}
+	for _, r := range p.rows() {
+		line := []string{r}
+		for _, c := range cols {
+			if v, ok := p.cells[r][c]; ok {
+				line = append(line, fmtNum(v))
+			} else {
+				line = append(line, "")
+			}
+		}
+		grid = append(grid, append(line, fmtNum(p.rowTot[r])))
+	}
+	line := []string{"Total"}
+	for _, c := range cols {
+		line = append(line, fmtNum(p.colTot[c]))
+	}
+	return append(grid, append(line, fmtNum(p.total)))
+}
+
+func renderPivot(w io.Writer, grid [][]string, format string) {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.WriteAll(grid)
+	case "md", "markdown":
+		for i, line := range grid {
+			cells := make([]string, len(line))
+			for j, cell := range line {
+				if i == len(grid)-1 || j == len(line)-1 {
+					cell = "**" + cell + "**"
+				}
+				cells[j] = strings.ReplaceAll(cell, "|", `\|`)
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+			if i == 0 {
+				seps := make([]string, len(line))
+				seps[0] = "---"
+				for j := 1; j < len(line); j++ {
+					seps[j] = "---:"
+				}
+				fmt.Fprintf(w, "| %s |\n", strings.Join(seps, " | "))
+			}
+		}
+	default:
+		widths := make([]int, len(grid[0]))
+		for _, line := range grid {
+			for j, cell := range line {
+				widths[j] = max(widths[j], len([]rune(cell)))
+			}
+		}
+		for i, line := range grid {
+			var b strings.Builder
+			for j, cell := range line {
+				pad := strings.Repeat(" ", widths[j]-len([]rune(cell)))
+				if j == 0 {
+					b.WriteString(cell + pad)
+				} else {
+					b.WriteString("  " + pad + cell)
+				}
+			}
+			fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+			if i == 0 || i == len(grid)-2 {
+				total := len(widths) - 1
+				for _, wd := range widths {
+					total += wd + 1
+				}
+				fmt.Fprintln(w, strings.Repeat("-", total))
+			}
+		}
+	}
+}
+
+// cmdReport pulls every issue matching the JQL and prints a pivot table of
+// issue counts (or field sums) by two fields. Totals count distinct issues,
+// so they stay consistent with the issue count when a multi-valued field
+// puts one issue in several cells.
+//
+//	<host> report <JQL|@alias k=v...> --rows assignee --cols status
+//	              [--sum "Story Points"] [--format text|md|csv] [--per-project]
+func cmdReport(c *apiClient, args []string) {
+	n := 0
+	for n < len(args) && !strings.HasPrefix(args[n], "-") {
+		n++
+	}
+	if n == 0 {
+		die("Usage: report <JQL|@alias> --rows <field> --cols <field> [--sum <field>] [--format text|md|csv] [--per-project]")
+	}
+	jql, _, err := resolveJQLArgs(args[:n])
+	if err != nil {
+		die("%s", err)
+	}
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	rowsFlag := fs.String("rows", "assignee", "field for rows")
+	colsFlag := fs.String("cols", "status", "field for columns")
+	sumFlag := fs.String("sum", "", "numeric field to sum instead of counting issues")
+	format := fs.String("format", "text", "text, md or csv")
+	perProject := fs.Bool("per-project", false, "also print one table per project")
+	_ = fs.Parse(args[n:])
+
+	data, err := c.get("/field", nil)
+	if err != nil {
+		die("%s", err)
+	}
+	var fieldDefs []any
+	json.Unmarshal(data, &fieldDefs)
+	rowID, err := resolveFieldID(*rowsFlag, fieldDefs)
+	if err != nil {
+		die("%s", err)
+	}
+	colID, err := resolveFieldID(*colsFlag, fieldDefs)
+	if err != nil {
+		die("%s", err)
+	}
+	wanted := []string{rowID, colID, "project"}
+	sumID := ""
+	if *sumFlag != "" {
+		if sumID, err = resolveFieldID(*sumFlag, fieldDefs); err != nil {
+			die("%s", err)
+		}
+		wanted = append(wanted, sumID)
+	}
+
+	issues, err := searchAll(c, jql, strings.Join(wanted, ","), "")
+	if err != nil {
+		die("%s", explainJQLError(jql, err))
+	}
+	overall := newPivot()
+	byProject := map[string]*pivot{}
+	for _, im := range issues {
+		fields := jsonMap(im, "fields")
+		v := 1.0
+		if sumID != "" {
+			f, _ := fields[sumID].(float64)
+			v = f
+		}
+		proj := jsonStr(jsonMap(fields, "project"), "key")
+		if byProject[proj] == nil {
+			byProject[proj] = newPivot()
+		}
+		key := jsonStr(im, "key")
+		for _, r := range fieldValues(fields[rowID]) {
+			for _, col := range fieldValues(fields[colID]) {
+				overall.add(key, r, col, v)
+				byProject[proj].add(key, r, col, v)
+			}
+		}
+	}
+
+	measure := "issue count"
+	if sumID != "" {
+		measure = "sum of " + *sumFlag
+	}
+	if len(issues) == 0 {
+		fmt.Println("No issues match.")
+		return
+	}
+	title := func(s string) {
+		switch *format {
+		case "csv":
+			// Keep CSV parseable: the table header carries the context.
+		case "md", "markdown":
+			fmt.Printf("### %s\n\n", s)
+		default:
+			fmt.Printf("%s\n\n", s)
+		}
+	}
+	if *perProject && len(byProject) > 1 {
+		var keys []string
+		for k := range byProject {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			title(fmt.Sprintf("%s: %s by %s (%s)", k, *rowsFlag, *colsFlag, measure))
+			renderPivot(os.Stdout, byProject[k].table(k+": "+*rowsFlag), *format)
+			fmt.Println()
+		}
+	}
+	title(fmt.Sprintf("%s by %s (%s, %d issues)", *rowsFlag, *colsFlag, measure, len(issues)))
+	renderPivot(os.Stdout, overall.table(*rowsFlag), *format)
+	if overall.fannedOut && *format != "csv" {
+		fmt.Println("\nIssues with several values appear in several cells; row, column and grand totals count each issue once.")
+	}
+}
+
+// ── Follow mode ─────────────────────────────────────────────
+
+// followCursor records the newest changelog and comment IDs already emitted
+// for one issue. Jira allocates both IDs from increasing sequences, so
+// anything greater than the cursor is new.
+type followCursor struct {
+	Changelog int64 `json:"changelog"`
+	Comment   int64 `json:"comment"`
+}
+
+type followState struct {
+	LastRun time.Time                `json:"lastRun"`
+	Issues  map[string]*followCursor `json:"issues"`
+}
+
+// configPath places local state under $XDG_CONFIG_HOME (default
+// ~/.config) on every platform so the path in the docs is the real one.
+func configPath(name string) string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "jira-navigator", name)
+}
+
+func defaultFollowStatePath(c *apiClient) string {
+	host := strings.TrimPrefix(c.baseURL, "https://")
+	return configPath("follow-" + host + ".json")
+}
+
+func loadFollowState(path string) (*followState, error) {
+	st := &followState{Issues: map[string]*followCursor{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if st.Issues == nil {
+		st.Issues = map[string]*followCursor{}
+	}
+	return st, nil
+}
+
+// saveFollowState writes via a temp file + rename so an interrupted run
+// never leaves a truncated cursor behind.
+func saveFollowState(path string, st *followState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// stripOrderBy drops a trailing ORDER BY clause so the JQL can be wrapped
+// in parentheses and combined with extra conditions.
+func stripOrderBy(jql string) string {
+	if i := strings.Index(strings.ToUpper(jql), "ORDER BY"); i >= 0 {
+		return strings.TrimSpace(jql[:i])
+	}
+	return strings.TrimSpace(jql)
+}
+
+// searchAll pages through /search until every matching issue is fetched.
+func searchAll(c *apiClient, jql, fields, expand string) ([]map[string]any, error) {
+	var issues []map[string]any
+	startAt := 0
+	for {
+		params := url.Values{
+			"jql":        {jql},
+			"startAt":    {strconv.Itoa(startAt)},
+			"maxResults": {"50"},
+			"fields":     {fields},
+		}
+		if expand != "" {
+			params.Set("expand", expand)
+		}
+		data, err := c.get("/search", params)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]any
+		json.Unmarshal(data, &m)
+		page := jsonArr(m, "issues")
+		for _, issue := range page {
+			if im := asMap(issue); im != nil {
+				issues = append(issues, im)
+			}
+		}
+		startAt += len(page)
+		total, _ := strconv.Atoi(jsonStr(m, "total"))
+		if len(page) == 0 || startAt >= total {
+			return issues, nil
+		}
+	}
+}
+
+func parseJiraTime(s string) time.Time {
+	t, err := time.Parse("2006-01-02T15:04:05.000-0700", s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// followOnce runs a single poll: it fetches issues updated since the last
+// run, emits one JSONL event per unseen changelog item or comment, and
+// advances the cursor. The first run only seeds the cursor.
+func followOnce(c *apiClient, jql string, st *followState, enc *json.Encoder) (int, error) {
+	now := time.Now().UTC()
+	seeding := st.LastRun.IsZero()
+	window := "-7d"
+	if !seeding {
+		// Relative JQL dates avoid server/client time zone mismatches; pad
+		// by a few minutes to cover clock skew and indexing lag.
+		window = fmt.Sprintf("-%dm", int(now.Sub(st.LastRun).Minutes())+5)
+	}
+	query := fmt.Sprintf("(%s) AND updated >= %s ORDER BY updated ASC", stripOrderBy(jql), window)
+	issues, err := searchAll(c, query, "summary,comment", "changelog")
+	if err != nil {
+		return 0, err
+	}
+
+	emitted := 0
+	for _, im := range issues {
+		key := jsonStr(im, "key")
+		fields := jsonMap(im, "fields")
+		summary := jsonStr(fields, "summary")
+		cur, known := st.Issues[key]
+		if cur == nil {
+			cur = &followCursor{}
+			st.Issues[key] = cur
+		}
+		// An issue that newly matches the JQL has no cursor; report only
+		// activity that happened after the previous run.
+		isNew := func(id, seen int64, created string) bool {
+			if seeding {
+				return false
+			}
+			if known {
+				return id > seen
+			}
+			return !parseJiraTime(created).Before(st.LastRun)
+		}
+
+		histories := jsonArr(jsonMap(im, "changelog"), "histories")
+		maxHist := cur.Changelog
+		for _, h := range histories {
+			hm := asMap(h)
+			if hm == nil {
+				continue
+			}
+			id, _ := strconv.ParseInt(jsonStr(hm, "id"), 10, 64)
+			if id > maxHist {
+				maxHist = id
+			}
+			if !isNew(id, cur.Changelog, jsonStr(hm, "created")) {
+				continue
+			}
+			author := jsonMap(hm, "author")
+			for _, item := range jsonArr(hm, "items") {
+				itm := asMap(item)
+				if itm == nil {
+					continue
+				}
+				enc.Encode(map[string]any{
+					"type":    "change",
+					"issue":   key,
+					"summary": summary,
+					"id":      jsonStr(hm, "id"),
+					"author":  strOr(jsonStr(author, "name"), jsonStr(author, "displayName")),
+					"created": jsonStr(hm, "created"),
+					"field":   jsonStr(itm, "field"),
+					"from":    jsonStr(itm, "fromString"),
+					"to":      jsonStr(itm, "toString"),
+				})
+				emitted++
+			}
+		}
+
+		comments := jsonArr(jsonMap(fields, "comment"), "comments")
+		maxComment := cur.Comment
+		for _, cm := range comments {
+			cmm := asMap(cm)
+			if cmm == nil {
+				continue
+			}
+			id, _ := strconv.ParseInt(jsonStr(cmm, "id"), 10, 64)
+			if id > maxComment {
+				maxComment = id
+			}
+			if !isNew(id, cur.Comment, jsonStr(cmm, "created")) {
+				continue
+			}
+			author := jsonMap(cmm, "author")
+			enc.Encode(map[string]any{
+				"type":    "comment",
+				"issue":   key,
+				"summary": summary,
+				"id":      jsonStr(cmm, "id"),
+				"author":  strOr(jsonStr(author, "name"), jsonStr(author, "displayName")),
+				"created": jsonStr(cmm, "created"),
+				"body":    jsonStr(cmm, "body"),
+			})
+			emitted++
+		}
+		cur.Changelog = maxHist
+		cur.Comment = maxComment
+	}
+	st.LastRun = now
+	return emitted, nil
+}
+
+// cmdFollow prints new field changes and comments since the previous run
+// as JSONL, keeping a per-issue cursor in a state file.
+//
+//	<host> follow [JQL] [--state path] [--interval 2m]
+//
+// Without --interval it polls once and exits; with it, it keeps polling.
+// The first run against an empty state file seeds the cursor silently.
+func cmdFollow(c *apiClient, args []string) {
+	jql := "watcher = currentUser()"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		jql = args[0]
+		args = args[1:]
+	}
+	fs := flag.NewFlagSet("follow", flag.ExitOnError)
+	statePath := fs.String("state", defaultFollowStatePath(c), "cursor state file")
+	interval := fs.Duration("interval", 0, "poll continuously at this interval (0 = run once)")
+	_ = fs.Parse(args)
+
+	st, err := loadFollowState(*statePath)
+	if err != nil {
+		die("load state: %v", err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		seeding := st.LastRun.IsZero()
+		n, err := followOnce(c, jql, st, enc)
+		if err != nil {
+			if *interval == 0 {
+				die("follow: %v", err)
+			}
+			fmt.Fprintf(os.Stderr, "follow: %v (retrying in %s)\n", err, *interval)
+		} else {
+			if err := saveFollowState(*statePath, st); err != nil {
+				die("save state: %v", err)
+			}
+			if seeding {
+				fmt.Fprintf(os.Stderr, "follow: seeded cursor for %d issue(s) in %s\n", len(st.Issues), *statePath)
+			} else if *interval == 0 && n == 0 {
+				fmt.Fprintln(os.Stderr, "follow: no new activity")
+			}
+		}
+		if *interval == 0 {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// ── Webhook receiver ────────────────────────────────────────
+
+// webhookEvent is the normalized form written for every accepted Jira
+// webhook delivery, independent of the payload shape of each event type.
+type webhookEvent struct {
+	Received  string          `json:"received"`
+	Timestamp string          `json:"timestamp,omitempty"`
+	Event     string          `json:"event"`
+	Issue     string          `json:"issue,omitempty"`
+	Summary   string          `json:"summary,omitempty"`
+	Project   string          `json:"project,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	User      string          `json:"user,omitempty"`
+	Changes   []webhookChange `json:"changes,omitempty"`
+	Comment   *webhookComment `json:"comment,omitempty"`
+	Sprint    *webhookSprint  `json:"sprint,omitempty"`
+}
+
+type webhookChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type webhookComment struct {
+	ID     string `json:"id"`
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+type webhookSprint struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// webhookMaxBody caps a single delivery; Jira payloads with full issue
+// bodies are well under this.
+const webhookMaxBody = 5 << 20
+
+// verifyWebhookSecret accepts either an HMAC-SHA256 signature in
+// X-Hub-Signature (Jira DC 10+, "sha256=<hex>") or a shared secret passed
+// as ?secret= in the registered webhook URL (older Server/DC releases that
+// cannot sign deliveries).
+func verifyWebhookSecret(r *http.Request, body []byte, secret string) bool {
+	if secret == "" {
+		return true
+	}
+	if sig := r.Header.Get("X-Hub-Signature"); sig != "" {
+		want, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(mac.Sum(nil), want)
+	}
+	got := r.URL.Query().Get("secret")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
+
+// normalizeWebhook converts a raw Jira webhook payload into a webhookEvent.
+// It rejects payloads without a webhookEvent field and event types outside
+// the issue, comment and sprint families.
+func normalizeWebhook(body []byte) (*webhookEvent, error) {
+	var m map[string]any
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	name := jsonStr(m, "webhookEvent")
+	if name == "" {
+		return nil, fmt.Errorf("missing webhookEvent")
+	}
+	kind := strings.TrimPrefix(name, "jira:")
+	if !strings.HasPrefix(kind, "issue_") && !strings.HasPrefix(kind, "comment_") && !strings.HasPrefix(kind, "sprint_") {
+		return nil, fmt.Errorf("unsupported webhookEvent %q", name)
+	}
+
+	ev := &webhookEvent{
+		Received: time.Now().UTC().Format(time.RFC3339),
+		Event:    kind,
+	}
+	if ms, ok := m["timestamp"].(float64); ok {
+		ev.Timestamp = time.UnixMilli(int64(ms)).UTC().Format(time.RFC3339)
+	}
+	if user := jsonMap(m, "user"); user != nil {
+		ev.User = strOr(jsonStr(user, "name"), jsonStr(user, "displayName"))
+	}
+	if issue := jsonMap(m, "issue"); issue != nil {
+		fields := jsonMap(issue, "fields")
+		ev.Issue = jsonStr(issue, "key")
+		ev.Summary = jsonStr(fields, "summary")
+		ev.Project = jsonStr(jsonMap(fields, "project"), "key")
+		ev.Status = jsonStr(jsonMap(fields, "status"), "name")
+	}
+	for _, item := range jsonArr(jsonMap(m, "changelog"), "items") {
+		if im := asMap(item); im != nil {
+			ev.Changes = append(ev.Changes, webhookChange{
+				Field: jsonStr(im, "field"),
+				From:  jsonStr(im, "fromString"),
+				To:    jsonStr(im, "toString"),
+			})
+		}
+	}
+	if cm := jsonMap(m, "comment"); cm != nil {
+		author := jsonMap(cm, "author")
+		ev.Comment = &webhookComment{
+			ID:     jsonStr(cm, "id"),
+			Author: strOr(jsonStr(author, "name"), jsonStr(author, "displayName")),
+			Body:   jsonStr(cm, "body"),
+		}
+		if ev.User == "" {
+			ev.User = ev.Comment.Author
+		}
+	}
+	if sm := jsonMap(m, "sprint"); sm != nil {
+		ev.Sprint = &webhookSprint{
+			ID:    jsonStr(sm, "id"),
+			Name:  jsonStr(sm, "name"),
+			State: jsonStr(sm, "state"),
+		}
+	}
+	return ev, nil
+}
+
+// webhookSink delivers normalized events to a JSONL file (or stdout) and,
+// optionally, to a command run once per event with the JSON on stdin.
+// Commands run on a single worker so a slow one never holds up the HTTP
+// response; Jira times out and retries deliveries that take too long.
+// With wait set (replay), deliver blocks on a full queue instead.
+type webhookSink struct {
+	mu      sync.Mutex
+	out     io.Writer
+	command string
+	timeout time.Duration
+	wait    bool
+	queue   chan webhookJob
+	done    chan struct{}
+}
+
+type webhookJob struct {
+	ev   *webhookEvent
+	line []byte
+}
+
+// webhookQueueDepth bounds events waiting for --exec; beyond it live
+// events are still written to the JSONL sink but skipped for the command.
+const webhookQueueDepth = 256
+
+func newWebhookSink(out io.Writer, command string, timeout time.Duration) *webhookSink {
+	s := &webhookSink{out: out, command: command, timeout: timeout}
+	if command != "" {
+		s.queue = make(chan webhookJob, webhookQueueDepth)
+		s.done = make(chan struct{})
+		go func() {
+			defer close(s.done)
+			for job := range s.queue {
+				if err := s.run(job); err != nil {
+					fmt.Fprintf(os.Stderr, "webhook: %s %s: %v\n", job.ev.Event, job.ev.Issue, err)
+				}
+			}
+		}()
+	}
+	return s
+}
+
+// close waits for queued commands to finish.
+func (s *webhookSink) close() {
+	if s.queue != nil {
+		close(s.queue)
+		<-s.done
+	}
+}
+
+func (s *webhookSink) deliver(ev *webhookEvent) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if s.out != nil {
+		s.mu.Lock()
+		_, err := s.out.Write(append(line, '\n'))
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if s.queue == nil {
+		return nil
+	}
+	if s.wait {
+		s.queue <- webhookJob{ev: ev, line: line}
+		return nil
+	}
+	select {
+	case s.queue <- webhookJob{ev: ev, line: line}:
+	default:
+		fmt.Fprintf(os.Stderr, "webhook: exec queue full, skipping command for %s %s\n", ev.Event, ev.Issue)
+	}
+	return nil
+}
+
+func (s *webhookSink) run(job webhookJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", s.command)
+	cmd.Stdin = bytes.NewReader(job.line)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(),
+		"JIRA_EVENT="+job.ev.Event,
+		"JIRA_ISSUE="+job.ev.Issue,
+		"JIRA_USER="+job.ev.User,
+	)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("exec %q: timed out after %s", s.command, s.timeout)
+		}
+		return fmt.Errorf("exec %q: %w", s.command, err)
+	}
+	return nil
+}
+
+func (s *webhookSink) handler(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxBody+1))
+		if err != nil {
+			http.Error(w, "read error", http.StatusBadRequest)
+			return
+		}
+		if len(body) > webhookMaxBody {
+			http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if !verifyWebhookSecret(r, body, secret) {
+			fmt.Fprintf(os.Stderr, "webhook: rejected delivery from %s (bad secret)\n", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		ev, err := normalizeWebhook(body)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "webhook: rejected delivery from %s: %v\n", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.deliver(ev); err != nil {
+			fmt.Fprintf(os.Stderr, "webhook: %v\n", err)
+			http.Error(w, "delivery failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// cmdWebhookListen runs a local receiver for Jira webhooks and writes one
+// normalized JSON event per line.
+//
+//	webhook-listen [--addr :8080] [--secret S] [--out events.jsonl] [--exec "cmd" [--exec-timeout 30s]]
+//	webhook-listen --replay payload.json [payload2.json ...]
+//
+// --replay feeds recorded payloads through the same normalization and
+// delivery path without opening a socket. --exec commands run one at a
+// time in the background, after the delivery has been acknowledged.
+func cmdWebhookListen(args []string) {
+	fs := flag.NewFlagSet("webhook-listen", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	secret := fs.String("secret", "", "shared secret (HMAC X-Hub-Signature or ?secret= query param)")
+	outPath := fs.String("out", "", "append events to this JSONL file (default: stdout)")
+	command := fs.String("exec", "", "run this shell command per event (event JSON on stdin)")
+	execTimeout := fs.Duration("exec-timeout", 30*time.Second, "kill an --exec command after this long")
+	path := fs.String("path", "/", "URL path to accept deliveries on")
+	replay := fs.Bool("replay", false, "process recorded payload files given as arguments, then exit")
+	_ = fs.Parse(args)
+	if *replay && fs.NArg() == 0 {
+		die("webhook-listen --replay: at least one payload file is required")
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.OpenFile(*outPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			die("open %s: %v", *outPath, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	sink := newWebhookSink(out, *command, *execTimeout)
+
+	if *replay {
+		// Nothing is time-critical here: wait for queue space, and let
+		// commands already queued finish before exiting on an error.
+		sink.wait = true
+		fail := func(format string, a ...any) {
+			sink.close()
+			die(format, a...)
+		}
+		for _, p := range fs.Args() {
+			data, err := os.ReadFile(p)
+			if err != nil {
+				fail("read %s: %v", p, err)
+			}
+			ev, err := normalizeWebhook(data)
+			if err != nil {
+				fail("%s: %v", p, err)
+			}
+			if err := sink.deliver(ev); err != nil {
+				fail("%s: %v", p, err)
+			}
+		}
+		sink.close()
+		return
+	}
+
+	if *secret == "" {
+		fmt.Fprintln(os.Stderr, "webhook: WARNING no --secret set, accepting unauthenticated deliveries")
+	}
+	mux := http.NewServeMux()
+	mux.Handle(*path, sink.handler(*secret))
+	fmt.Fprintf(os.Stderr, "webhook: listening on %s%s\n", *addr, *path)
+	srv := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	if err := srv.ListenAndServe(); err != nil {
+		die("listen: %v", err)
+	}
+}
+
+// ── Duplicate detection ─────────────────────────────────────
+
+// dupStopWords are dropped before searching and scoring because they are
+// too common to tell issues apart.
+var dupStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "for": true, "from": true, "has": true,
+	"in": true, "is": true, "it": true, "not": true, "of": true, "on": true,
+	"or": true, "should": true, "that": true, "the": true, "this": true,
+	"to": true, "was": true, "when": true, "with": true, "we": true, "will": true,
+	"fix": true, "issue": true, "add": true, "use": true,
+}
+
+// textTokens lowercases s and splits it into words of letters and digits
+// in any script, dropping stop words and single characters.
+func textTokens(s string) []string {
+	var out []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(w) > 1 && !dupStopWords[w] {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// cosineSim scores two token lists by cosine similarity of their term
+// frequency vectors (0 = nothing shared, 1 = same words).
+func cosineSim(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	fa, fb := map[string]float64{}, map[string]float64{}
+	for _, t := range a {
+		fa[t]++
+	}
+	for _, t := range b {
+		fb[t]++
+	}
+	var dot, na, nb float64
+	for t, v := range fa {
+		dot += v * fb[t]
+		na += v * v
+	}
+	for _, v := range fb {
+		nb += v * v
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// dupSearchLimit caps how many search hits findSimilar ranks locally.
+const dupSearchLimit = 200
+
+type dupCandidate struct {
+	Key     string
+	Summary string
+	Status  string
+	Open    bool
+	Score   float64
+}
+
+// findSimilar runs a full-text search for the most distinctive words of
+// summary (and description) and ranks the hits locally. The query has no
+// ORDER BY, so Jira returns the most relevant hits first whatever their
+// age. The summary match dominates the score; the description only
+// breaks ties between issues with similar titles.
+func findSimilar(c *apiClient, project, summary, desc string) ([]dupCandidate, error) {
+	sumTokens := textTokens(summary)
+	if len(sumTokens) == 0 {
+		return nil, fmt.Errorf("summary has no searchable words")
+	}
+	seen := map[string]bool{}
+	var terms []string
+	for _, t := range sumTokens {
+		if !seen[t] && len(terms) < 8 {
+			seen[t] = true
+			terms = append(terms, fmt.Sprintf(`text ~ "%s"`, t))
+		}
+	}
+	jql := "(" + strings.Join(terms, " OR ") + ")"
+	if project != "" {
+		jql = fmt.Sprintf(`project = "%s" AND %s`, project, jql)
+	}
+	var issues []map[string]any
+	for len(issues) < dupSearchLimit {
+		data, err := c.get("/search", url.Values{
+			"jql":        {jql},
+			"startAt":    {strconv.Itoa(len(issues))},
+			"maxResults": {"100"},
+			"fields":     {"summary,description,status,resolution"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]any
+		json.Unmarshal(data, &m)
+		page := jsonArr(m, "issues")
+		for _, issue := range page {
+			if im := asMap(issue); im != nil {
+				issues = append(issues, im)
+			}
+		}
+		total, _ := strconv.Atoi(jsonStr(m, "total"))
+		if len(page) == 0 || len(issues) >= total {
+			break
+		}
+	}
+
+	allTokens := textTokens(summary + " " + desc)
+	var cands []dupCandidate
+	for _, im := range issues {
+		fields := jsonMap(im, "fields")
+		status := jsonMap(fields, "status")
+		candSummary := jsonStr(fields, "summary")
+		score := 0.7*cosineSim(sumTokens, textTokens(candSummary)) +
+			0.3*cosineSim(allTokens, textTokens(candSummary+" "+jsonStr(fields, "description")))
+		cands = append(cands, dupCandidate{
+			Key:     jsonStr(im, "key"),
+			Summary: candSummary,
+			Status:  jsonStr(status, "name"),
+			Open:    jsonMap(fields, "resolution") == nil && jsonStr(jsonMap(status, "statusCategory"), "key") != "done",
+			Score:   score,
+		})
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].Score > cands[j].Score })
+	return cands, nil
+}
+
+func printCandidates(w io.Writer, cands []dupCandidate) {
+	for _, d := range cands {
+		state := "open"
+		if !d.Open {
+			state = "closed"
+		}
+		fmt.Fprintf(w, "  %.2f  %s [%s, %s] %s\n", d.Score, d.Key, d.Status, state, d.Summary)
+	}
+}
+
+// cmdSimilar lists existing issues that look like the given summary.
+//
+//	<host> similar "<summary>" [--project KEY] [--desc ... | --desc-file path] [--limit 10]
+func cmdSimilar(c *apiClient, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		die("Usage: similar \"<summary>\" [--project KEY] [--desc ...] [--limit 10]")
+	}
+	summary := args[0]
+	fs := flag.NewFlagSet("similar", flag.ExitOnError)
+	project := fs.String("project", "", "restrict the search to this project key")
+	desc := fs.String("desc", "", "description text to compare as well")
+	descFile := fs.String("desc-file", "", "read description from file")
+	limit := fs.Int("limit", 10, "number of candidates to show")
+	_ = fs.Parse(args[1:])
+
+	cands, err := findSimilar(c, *project, summary, readBody(*desc, *descFile, false))
+	if err != nil {
+		die("similar: %v", err)
+	}
+	if len(cands) > *limit {
+		cands = cands[:*limit]
+	}
+	if len(cands) == 0 {
+		fmt.Println("No similar issues found.")
+		return
+	}
+	fmt.Printf("Similar issues (score 0-1):\n\n")
+	printCandidates(os.Stdout, cands)
+}
+
+// ── Help ────────────────────────────────────────────────────
+
+// ── Write commands ──────────────────────────────────────────
+
+// readBody returns the body text: --body wins, then --body-file, then stdin
+// when --body-stdin is set. Exits with a clear error if more than one is set
+// or none is set (callers that accept "no body" should validate before calling).
+func readBody(flagVal, fileVal string, stdinFlag bool) string {
+	set := 0
+	if flagVal != "" {
+		set++
+	}
+	if fileVal != "" {
+		set++
+	}
+	if stdinFlag {
+		set++
+	}
+	if set > 1 {
+		die("pass at most one of --body, --body-file, --body-stdin")
+	}
+	switch {
+	case flagVal != "":
+		return flagVal
+	case fileVal != "":
+		data, err := os.ReadFile(fileVal)
+		if err != nil {
+			die("read %s: %v", fileVal, err)
+		}
+		return string(data)
+	case stdinFlag:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			die("read stdin: %v", err)
+		}
+		return string(data)
+	default:
+		return ""
+	}
+}
+
+// cmdCreateIssue creates a new Jira issue and prints the new key.
+//
+//	<host> create-issue --project SR --type Story --summary "..."
+//	                    [--epic SR-1416] [--assignee user]
+//	                    [--priority Medium] [--labels a,b,c]
+//	                    [--desc "..."] [--desc-file path] [--desc-stdin]
+//	                    [--epic-field customfield_10101]
+//	                    [--check-duplicates [--dup-threshold 0.6] [--dup-action abort|warn]]
+//
+// With --check-duplicates, open issues in the project whose similarity
+// score reaches the threshold are listed on stderr and, by default, the
+// issue is not created.
+func cmdCreateIssue(c *apiClient, args []string) {
+	fs := flag.NewFlagSet("create-issue", flag.ExitOnError)
+	project := fs.String("project", "", "project key (required)")
+	issueType := fs.String("type", "Story", "issue type name (Story, Task, Bug, ...)")
+	summary := fs.String("summary", "", "issue summary (required)")
+	epic := fs.String("epic", "", "epic link key (e.g. SR-1416) — uses --epic-field")
+	epicField := fs.String("epic-field", "customfield_10101", "custom field ID used for epic link on this instance")
+	assignee := fs.String("assignee", "", "assignee username (default: current user)")
+	priority := fs.String("priority", "", "priority name (optional)")
+	labels := fs.String("labels", "", "comma-separated labels (optional)")
+	desc := fs.String("desc", "", "description text")
+	descFile := fs.String("desc-file", "", "read description from file")
+	descStdin := fs.Bool("desc-stdin", false, "read description from stdin")
+	checkDup := fs.Bool("check-duplicates", false, "search the project for near-duplicate open issues first")
+	dupThreshold := fs.Float64("dup-threshold", 0.6, "similarity score (0-1) treated as a near-duplicate")
+	dupAction := fs.String("dup-action", "abort", "on near-duplicate: abort or warn")
+	_ = fs.Parse(args)
+
+	if *project == "" || *summary == "" {
+		die("create-issue: --project and --summary are required")
+	}
+	if *dupAction != "abort" && *dupAction != "warn" {
+		die("create-issue: --dup-action must be abort or warn")
+	}
+	description := readBody(*desc, *descFile, *descStdin)
+
+	if *checkDup {
+		cands, err := findSimilar(c, *project, *summary, description)
+		if err != nil {
+			die("duplicate check: %v", err)
+		}
+		var dups []dupCandidate
+		for _, d := range cands {
+			if d.Open && d.Score >= *dupThreshold {
+				dups = append(dups, d)
+			}
+		}
+		if len(dups) > 0 {
+			fmt.Fprintf(os.Stderr, "Possible duplicate open issue(s) in %s (score >= %.2f):\n", *project, *dupThreshold)
+			printCandidates(os.Stderr, dups)
+			if *dupAction == "abort" {
+				die("create-issue: not created; comment on %s instead or rerun with --dup-action warn", dups[0].Key)
+			}
+		}
+	}
+
+	fields := map[string]any{
+		"project":   map[string]string{"key": *project},
+		"issuetype": map[string]string{"name": *issueType},
+		"summary":   *summary,
+	}
+	if description != "" {
+		fields["description"] = description
+	}
+	if *epic != "" {
+		fields[*epicField] = *epic
+	}
+	if *assignee != "" {
+		fields["assignee"] = map[string]string{"name": *assignee}
+	}
+	if *priority != "" {
+		fields["priority"] = map[string]string{"name": *priority}
+	}
+	if *labels != "" {
+		var lbls []string
+		for _, s := range strings.Split(*labels, ",") {
+			if t := strings.TrimSpace(s); t != "" {
+				lbls = append(lbls, t)
+			}
+		}
+		if len(lbls) > 0 {
+			fields["labels"] = lbls
+		}
+	}
+
+	payload, err := json.Marshal(map[string]any{"fields": fields})
+	if err != nil {
+		die("marshal payload: %v", err)
+	}
+
+	resp, err := c.post("/issue", payload)
+	if err != nil {
+		die("create issue: %v", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(resp, &out); err != nil {
+		die("parse response: %v", err)
+	}
+	key := jsonStr(out, "key")
+	if key == "" {
+		die("response missing key: %s", string(resp))
+	}
+	fmt.Println(key)
+}
+
+// parseVisibility turns "role:Developers" or "group:jira-admins" into the
+// comment visibility object Jira expects. Empty input means public.
+func parseVisibility(spec string) (map[string]string, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	kind, value, ok := strings.Cut(spec, ":")
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	value = strings.TrimSpace(value)
+	if !ok || value == "" || (kind != "role" && kind != "group") {
+		return nil, fmt.Errorf("invalid --visibility %q (want role:<name> or group:<name>)", spec)
+	}
+	return map[string]string{"type": kind, "value": value}, nil
+}
+
+// mentionRe matches @handle or @email at the start of the text or after
+// whitespace/opening punctuation, so addresses like a@b.com are left alone.
+var mentionRe = regexp.MustCompile(`(^|[\s(\[{"'])@([A-Za-z0-9._+-]+(?:@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)+)?)`)
+
+// searchUsers queries /user/search, falling back from Server/DC's
+// `username` parameter to Cloud's `query`.
+func searchUsers(c *apiClient, term string) ([]map[string]any, error) {
+	data, err := c.get("/user/search", url.Values{"username": {term}, "maxResults": {"10"}})
+	if err != nil {
+		var err2 error
+		data, err2 = c.get("/user/search", url.Values{"query": {term}, "maxResults": {"10"}})
+		if err2 != nil {
+			return nil, fmt.Errorf("username search: %w; query search: %w", err, err2)
+		}
+	}
+	var arr []any
+	json.Unmarshal(data, &arr)
+	var users []map[string]any
+	for _, u := range arr {
+		if um := asMap(u); um != nil {
+			users = append(users, um)
+		}
+	}
+	return users, nil
+}
+
+// mentionMarkup renders a user as wiki-markup mention: [~accountId:...] on
+// Cloud (accountId present), [~username] on Server/DC.
+func mentionMarkup(u map[string]any) string {
+	if id := jsonStr(u, "accountId"); id != "" {
+		return "[~accountId:" + id + "]"
+	}
+	return "[~" + jsonStr(u, "name") + "]"
+}
+
+// resolveMentions rewrites @name / @email tokens into Jira mention markup.
+// /user/search is a fuzzy prefix search, so only an exact match on
+// username, key or email is used; anything else (e.g. @Override or @param
+// in a code snippet) is left as typed with a warning, so a comment never
+// pings the wrong person.
+func resolveMentions(c *apiClient, text string) (string, error) {
+	cache := map[string]string{}
+	var firstErr error
+	out := mentionRe.ReplaceAllStringFunc(text, func(match string) string {
+		sub := mentionRe.FindStringSubmatch(match)
+		prefix, handle := sub[1], strings.TrimRight(sub[2], ".-")
+		trailing := sub[2][len(handle):]
+		if markup, ok := cache[handle]; ok {
+			return prefix + markup + trailing
+		}
+		users, err := searchUsers(c, handle)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("resolve @%s: %w", handle, err)
+			}
+			return match
+		}
+		var pick map[string]any
+		for _, u := range users {
+			if strings.EqualFold(jsonStr(u, "name"), handle) ||
+				strings.EqualFold(jsonStr(u, "key"), handle) ||
+				strings.EqualFold(jsonStr(u, "emailAddress"), handle) {
+				pick = u
+				break
+			}
+		}
+		if pick == nil {
+			if len(users) == 0 {
+				fmt.Fprintf(os.Stderr, "warning: no Jira user matches @%s; left as plain text\n", handle)
+			} else {
+				var names []string
+				for _, u := range users {
+					names = append(names, fmt.Sprintf("%s (%s)", strOr(jsonStr(u, "name"), jsonStr(u, "accountId")), jsonStr(u, "displayName")))
+				}
+				fmt.Fprintf(os.Stderr, "warning: no exact Jira user match for @%s (similar: %s); left as plain text\n", handle, strings.Join(names, ", "))
+			}
+			cache[handle] = "@" + handle
+			return match
+		}
+		cache[handle] = mentionMarkup(pick)
+		return prefix + cache[handle] + trailing
+	})
+	return out, firstErr
+}
+
+// commentPayload builds the request body shared by comment and
+// edit-comment: mention resolution plus optional visibility restriction.
+func commentPayload(c *apiClient, text, visibility string, mentions bool) ([]byte, error) {
+	if mentions {
+		var err error
+		if text, err = resolveMentions(c, text); err != nil {
+			return nil, err
+		}
+	}
+	payload := map[string]any{"body": text}
+	vis, err := parseVisibility(visibility)
+	if err != nil {
+		return nil, err
+	}
+	if vis != nil {
+		payload["visibility"] = vis
+	}
+	return json.Marshal(payload)
+}
+
+// cmdComment posts a comment on an issue.
+//
+//	<host> comment <key> --body "..."
+//	<host> comment <key> --body-file path
+//	<host> comment <key> --body-stdin
+//	<host> comment <key> --body "..." --visibility role:Developers
+//
+// @name and @email tokens in the body are rewritten to Jira mention markup
+// unless --no-mentions is given.
+func cmdComment(c *apiClient, args []string) {
+	if len(args) < 1 {
+		die("comment: <key> is required")
+	}
+	key := args[0]
+	fs := flag.NewFlagSet("comment", flag.ExitOnError)
+	body := fs.String("body", "", "comment body")
+	bodyFile := fs.String("body-file", "", "read comment body from file")
+	bodyStdin := fs.Bool("body-stdin", false, "read comment body from stdin")
+	visibility := fs.String("visibility", "", "restrict to role:<name> or group:<name>")
+	noMentions := fs.Bool("no-mentions", false, "do not resolve @mentions")
+	_ = fs.Parse(args[1:])
+
+	text := readBody(*body, *bodyFile, *bodyStdin)
+	if strings.TrimSpace(text) == "" {
+		die("comment: body is empty (use --body, --body-file, or --body-stdin)")
+	}
+
+	payload, err := commentPayload(c, text, *visibility, !*noMentions)
+	if err != nil {
+		die("comment: %v", err)
+	}
+	resp, err := c.post("/issue/"+url.PathEscape(key)+"/comment", payload)
+	if err != nil {
+		die("post comment: %v", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(resp, &out); err != nil {
+		die("parse response: %v", err)
+	}
+	fmt.Printf("comment posted to %s: id=%s\n", key, jsonStr(out, "id"))
+}
+
+// cmdEditComment replaces the body of an existing comment.
+//
+//	<host> edit-comment <key> <comment-id> [--body ... | --body-file path | --body-stdin]
+//	                    [--visibility role:<name>|group:<name>] [--no-mentions]
+//
+// Note: this Jira instance (and many Server/DC installs with richer auto-
+// linking) treats comment bodies as plain text with only issue-key and URL
+// auto-linking. Wiki markup like h3., {{code}}, || tables || is NOT
+// interpreted — it will render literally. Use plain prose + blank lines.
+func cmdEditComment(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("edit-comment: <key> <comment-id> required")
+	}
+	key := args[0]
+	commentID := args[1]
+
+	fs := flag.NewFlagSet("edit-comment", flag.ExitOnError)
+	body := fs.String("body", "", "new comment body")
+	bodyFile := fs.String("body-file", "", "read new comment body from file")
+	bodyStdin := fs.Bool("body-stdin", false, "read new comment body from stdin")
+	visibility := fs.String("visibility", "", "restrict to role:<name> or group:<name>")
+	noMentions := fs.Bool("no-mentions", false, "do not resolve @mentions")
+	_ = fs.Parse(args[2:])
+
+	text := readBody(*body, *bodyFile, *bodyStdin)
+	if strings.TrimSpace(text) == "" {
+		die("edit-comment: body is empty (use --body, --body-file, or --body-stdin)")
+	}
+
+	payload, err := commentPayload(c, text, *visibility, !*noMentions)
+	if err != nil {
+		die("edit-comment: %v", err)
+	}
+	if _, err := c.put("/issue/"+url.PathEscape(key)+"/comment/"+url.PathEscape(commentID), payload); err != nil {
+		die("edit comment: %v", err)
+	}
+	fmt.Printf("comment %s on %s updated\n", commentID, key)
+}
+
+// cmdDeleteComment removes a comment from an issue.
+//
+//	<host> delete-comment <key> <comment-id>
+func cmdDeleteComment(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("delete-comment: <key> <comment-id> required")
+	}
+	key := args[0]
+	commentID := args[1]
+	if err := c.delete("/issue/" + url.PathEscape(key) + "/comment/" + url.PathEscape(commentID)); err != nil {
+		die("delete comment: %v", err)
+	}
+	fmt.Printf("comment %s on %s deleted\n", commentID, key)
+}
+
+// cmdTransition moves an issue to a new status.
+//
+//	<host> transition <key> <transition-id>
+//	<host> transition <key> <transition-id> --comment "..."
+//
+// Use `transitions <key>` first to list the available IDs for that issue.
+func cmdTransition(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("transition: <key> <transition-id> required (use `transitions <key>` to list)")
+	}
+	key := args[0]
+	transitionID := args[1]
+
+	fs := flag.NewFlagSet("transition", flag.ExitOnError)
+	comment := fs.String("comment", "", "optional comment posted with the transition")
+	_ = fs.Parse(args[2:])
+
+	payload := map[string]any{
+		"transition": map[string]string{"id": transitionID},
+	}
+	if *comment != "" {
+		payload["update"] = map[string]any{
+			"comment": []map[string]any{
+				{"add": map[string]string{"body": *comment}},
+			},
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		die("marshal payload: %v", err)
+	}
+	if _, err := c.post("/issue/"+url.PathEscape(key)+"/transitions", body); err != nil {
+		die("transition: %v", err)
+	}
+	fmt.Printf("%s transitioned via id=%s\n", key, transitionID)
+}
+
+// ── Clone and move ──────────────────────────────────────────
+
+// createMeta returns the create-screen fields for an issue type in a
+// project, keyed by field ID. It uses the legacy expand form first and
+// falls back to the per-type endpoints that replaced it in Jira DC 9.
+func createMeta(c *apiClient, project, issueType string) (map[string]map[string]any, error) {
+	fields := map[string]map[string]any{}
+	data, err := c.get("/issue/createmeta", url.Values{
+		"projectKeys":    {project},
+		"issuetypeNames": {issueType},
+		"expand":         {"projects.issuetypes.fields"},
+	})
+	if err == nil {
+		var m map[string]any
+		json.Unmarshal(data, &m)
+		for _, p := range jsonArr(m, "projects") {
+			for _, it := range jsonArr(asMap(p), "issuetypes") {
+				for id, f := range jsonMap(asMap(it), "fields") {
+					fields[id] = asMap(f)
+				}
+			}
+		}
+		if len(fields) > 0 {
+			return fields, nil
+		}
+	}
+
+	data, err = c.get("/issue/createmeta/"+url.PathEscape(project)+"/issuetypes", url.Values{"maxResults": {"100"}})
+	if err != nil {
+		return nil, fmt.Errorf("createmeta for %s: %w", project, err)
+	}
+	var types map[string]any
+	json.Unmarshal(data, &types)
+	typeID := ""
+	for _, t := range jsonArr(types, "values") {
+		if tm := asMap(t); strings.EqualFold(jsonStr(tm, "name"), issueType) {
+			typeID = jsonStr(tm, "id")
+		}
+	}
+	if typeID == "" {
+		return nil, fmt.Errorf("issue type %q is not available in project %s", issueType, project)
+	}
+	data, err = c.get("/issue/createmeta/"+url.PathEscape(project)+"/issuetypes/"+typeID, url.Values{"maxResults": {"200"}})
+	if err != nil {
+		return nil, fmt.Errorf("createmeta for %s/%s: %w", project, issueType, err)
+	}
+	var fm map[string]any
+	json.Unmarshal(data, &fm)
+	for _, f := range jsonArr(fm, "values") {
+		if m := asMap(f); m != nil {
+			fields[jsonStr(m, "fieldId")] = m
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("issue type %q is not available in project %s", issueType, project)
+	}
+	return fields, nil
+}
+
+// mapOption finds the target option matching a source option by value or
+// name and returns it as an ID reference.
+func mapOption(v any, allowed []any) (any, bool) {
+	src := asMap(v)
+	label := strOr(jsonStr(src, "value"), jsonStr(src, "name"))
+	if label == "" {
+		if s, ok := v.(string); ok {
+			label = s
+		}
+	}
+	for _, a := range allowed {
+		am := asMap(a)
+		if label != "" && (jsonStr(am, "value") == label || jsonStr(am, "name") == label) {
+			return map[string]any{"id": jsonStr(am, "id")}, true
+		}
+	}
+	return nil, false
+}
+
+// mapFieldValue converts a source field value into something the target
+// create screen accepts. Option-like values are matched by name against
+// the target's allowedValues; users and plain values are passed through.
+func mapFieldValue(v any, meta map[string]any) (any, bool) {
+	allowed := jsonArr(meta, "allowedValues")
+	if arr, ok := v.([]any); ok {
+		if len(allowed) == 0 {
+			return arr, true
+		}
+		var out []any
+		for _, item := range arr {
+			mapped, ok := mapOption(item, allowed)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, mapped)
+		}
+		return out, true
+	}
+	if len(allowed) > 0 {
+		return mapOption(v, allowed)
+	}
+	if m := asMap(v); m != nil {
+		switch {
+		case jsonStr(m, "accountId") != "":
+			return map[string]any{"accountId": jsonStr(m, "accountId")}, true
+		case jsonStr(m, "name") != "":
+			return map[string]any{"name": jsonStr(m, "name")}, true
+		case jsonStr(m, "value") != "":
+			return map[string]any{"value": jsonStr(m, "value")}, true
+		case jsonStr(m, "key") != "":
+			return map[string]any{"key": jsonStr(m, "key")}, true
+		}
+		return nil, false
+	}
+	return v, true
+}
+
+// cloneSystemFields are the non-custom fields worth carrying over.
+var cloneSystemFields = []string{"summary", "description", "labels", "components", "priority", "environment", "duedate"}
+
+// buildCloneFields maps src fields onto the target create screen. It
+// returns the payload fields plus a human-readable list of fields that
+// could not be carried over.
+func buildCloneFields(src, names map[string]any, meta map[string]map[string]any) (map[string]any, []string) {
+	out := map[string]any{}
+	var skipped []string
+	label := func(id string) string {
+		n := strOr(jsonStr(names, id), jsonStr(meta[id], "name"))
+		if n != "" && n != id {
+			return fmt.Sprintf("%s (%s)", n, id)
+		}
+		return id
+	}
+	consider := func(id string, v any) {
+		if v == nil {
+			return
+		}
+		if arr, ok := v.([]any); ok && len(arr) == 0 {
+			return
+		}
+		if s, ok := v.(string); ok && s == "" {
+			return
+		}
+		fm, ok := meta[id]
+		if !ok {
+			skipped = append(skipped, label(id)+": not on the target create screen")
+			return
+		}
+		custom := jsonStr(jsonMap(fm, "schema"), "custom")
+		if strings.Contains(custom, "gh-lexo-rank") {
+			return
+		}
+		if strings.Contains(custom, "gh-sprint") {
+			skipped = append(skipped, label(id)+": sprint membership is not copied")
+			return
+		}
+		mapped, ok := mapFieldValue(v, fm)
+		if !ok {
+			skipped = append(skipped, label(id)+": value has no match in the target project")
+			return
+		}
+		out[id] = mapped
+	}
+	for _, id := range cloneSystemFields {
+		consider(id, src[id])
+	}
+	var custom []string
+	for id := range src {
+		if strings.HasPrefix(id, "customfield_") {
+			custom = append(custom, id)
+		}
+	}
+	sort.Strings(custom)
+	for _, id := range custom {
+		consider(id, src[id])
+	}
+	for id, fm := range meta {
+		if _, set := out[id]; set || id == "project" || id == "issuetype" || id == "parent" || id == "reporter" {
+			continue
+		}
+		if fm["required"] == true && fm["hasDefaultValue"] != true {
+			skipped = append(skipped, label(id)+": required by the target but has no value")
+		}
+	}
+	return out, skipped
+}
+
+func createLink(c *apiClient, linkType, inward, outward string) error {
+	body, _ := json.Marshal(map[string]any{
+		"type":         map[string]string{"name": linkType},
+		"inwardIssue":  map[string]string{"key": inward},
+		"outwardIssue": map[string]string{"key": outward},
+	})
+	_, err := c.post("/issueLink", body)
+	return err
+}
+
+// cloneIssue copies one issue into project (as issueType, or the source
+// type when empty) and returns the new key plus any unmapped fields.
+func cloneIssue(c *apiClient, src map[string]any, names map[string]any, project, issueType, parentKey string, dryRun bool) (string, []string, error) {
+	fields := jsonMap(src, "fields")
+	if issueType == "" {
+		issueType = jsonStr(jsonMap(fields, "issuetype"), "name")
+	}
+	meta, err := createMeta(c, project, issueType)
+	if err != nil {
+		return "", nil, err
+	}
+	out, skipped := buildCloneFields(fields, names, meta)
+	out["project"] = map[string]string{"key": project}
+	out["issuetype"] = map[string]string{"name": issueType}
+	if parentKey != "" {
+		out["parent"] = map[string]string{"key": parentKey}
+	}
+	if dryRun {
+		fmt.Printf("Would create in %s as %s:\n", project, issueType)
+		printJSON(map[string]any{"fields": out})
+		return "", skipped, nil
+	}
+	body, err := json.Marshal(map[string]any{"fields": out})
+	if err != nil {
+		return "", skipped, err
+	}
+	resp, err := c.post("/issue", body)
+	if err != nil {
+		return "", skipped, err
+	}
+	var m map[string]any
+	json.Unmarshal(resp, &m)
+	return jsonStr(m, "key"), skipped, nil
+}
+
+func fetchFullIssue(c *apiClient, key string) (map[string]any, map[string]any, error) {
+	data, err := c.get("/issue/"+url.PathEscape(key), url.Values{"fields": {"*all"}, "expand": {"names"}})
+	if err != nil {
+		return nil, nil, err
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	return m, jsonMap(m, "names"), nil
+}
+
+func printSkipped(key string, skipped []string) {
+	if len(skipped) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: fields not carried over:\n", key)
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "  - %s\n", s)
+	}
+}
+
+// cmdClone copies an issue (optionally into another project) and links
+// the clone to the original.
+//
+//	<host> clone <key> [--project OTHER] [--type T] [--with-subtasks] [--with-links]
+//	                   [--link-type Cloners] [--dry-run]
+func cmdClone(c *apiClient, args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		die("Usage: clone <key> [--project OTHER] [--type T] [--with-subtasks] [--with-links] [--dry-run]")
+	}
+	key := args[0]
+	fs := flag.NewFlagSet("clone", flag.ExitOnError)
+	project := fs.String("project", "", "target project key (default: same project)")
+	issueType := fs.String("type", "", "target issue type (default: same type)")
+	withSubtasks := fs.Bool("with-subtasks", false, "clone sub-tasks under the new issue")
+	withLinks := fs.Bool("with-links", false, "recreate the original's issue links on the clone")
+	linkType := fs.String("link-type", "Cloners", "link type used to connect clone and original")
+	dryRun := fs.Bool("dry-run", false, "print the create payload and unmapped fields only")
+	_ = fs.Parse(args[1:])
+
+	src, names, err := fetchFullIssue(c, key)
+	if err != nil {
+		die("%s", err)
+	}
+	fields := jsonMap(src, "fields")
+	target := strOr(*project, jsonStr(jsonMap(fields, "project"), "key"))
+
+	newKey, skipped, err := cloneIssue(c, src, names, target, *issueType, "", *dryRun)
+	printSkipped(key, skipped)
+	if err != nil {
+		die("clone %s: %v", key, err)
+	}
+	if *dryRun {
+		return
+	}
+	fmt.Printf("%s cloned to %s\n", key, newKey)
+	if err := createLink(c, *linkType, newKey, key); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: link %s -> %s (%s): %v\n", newKey, key, *linkType, err)
+	}
+
+	if *withSubtasks {
+		for _, st := range jsonArr(fields, "subtasks") {
+			stKey := jsonStr(asMap(st), "key")
+			stSrc, stNames, err := fetchFullIssue(c, stKey)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: sub-task %s: %v\n", stKey, err)
+				continue
+			}
+			stNew, stSkipped, err := cloneIssue(c, stSrc, stNames, target, "", newKey, false)
+			printSkipped(stKey, stSkipped)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: sub-task %s: %v\n", stKey, err)
+				continue
+			}
+			fmt.Printf("  sub-task %s cloned to %s\n", stKey, stNew)
+		}
+	}
+
+	if *withLinks {
+		for _, link := range jsonArr(fields, "issuelinks") {
+			lm := asMap(link)
+			lt := jsonStr(jsonMap(lm, "type"), "name")
+			var err error
+			var other string
+			if out := jsonMap(lm, "outwardIssue"); out != nil {
+				other = jsonStr(out, "key")
+				err = createLink(c, lt, newKey, other)
+			} else if in := jsonMap(lm, "inwardIssue"); in != nil {
+				other = jsonStr(in, "key")
+				err = createLink(c, lt, other, newKey)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: link %s (%s): %v\n", other, lt, err)
+			} else if other != "" {
+				fmt.Printf("  linked %s %s\n", lt, other)
+			}
+		}
+	}
+}
+
+// cmdMove moves an issue to another project where the instance allows it
+// through REST. Jira Server/DC generally rejects project changes outside
+// the bulk-move UI; the field-mapping report is printed either way so the
+// clone fallback can be judged.
+//
+//	<host> move <key> <project> [--type T]
+func cmdMove(c *apiClient, args []string) {
+	if len(args) < 2 {
+		die("Usage: move <key> <project> [--type T]")
+	}
+	key, project := args[0], args[1]
+	fs := flag.NewFlagSet("move", flag.ExitOnError)
+	issueType := fs.String("type", "", "issue type in the target project (default: same type)")
+	_ = fs.Parse(args[2:])
+
+	src, names, err := fetchFullIssue(c, key)
+	if err != nil {
+		die("%s", err)
+	}
+	fields := jsonMap(src, "fields")
+	typeName := strOr(*issueType, jsonStr(jsonMap(fields, "issuetype"), "name"))
+	meta, err := createMeta(c, project, typeName)
+	if err != nil {
+		die("%s", err)
+	}
+	_, skipped := buildCloneFields(fields, names, meta)
+	printSkipped(key, skipped)
+
+	body, _ := json.Marshal(map[string]any{"fields": map[string]any{
+		"project":   map[string]string{"key": project},
+		"issuetype": map[string]string{"name": typeName},
+	}})
+	if _, err := c.put("/issue/"+url.PathEscape(key), body); err != nil {
+		die("move %s: %v\nThis instance does not allow moving issues via REST; use `clone %s --project %s` and close the original instead.", key, err, key, project)
+	}
+	data, err := c.get("/issue/"+url.PathEscape(key), url.Values{"fields": {"project"}})
+	if err != nil {
+		die("%s", err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	fmt.Printf("%s moved to %s (now %s)\n", key, project, jsonStr(m, "key"))
+}
+
+func printHelp() {
+	fmt.Println(`Usage: jira-navigator <host> <command> [args...]
+
+Discovery:
+  discover [substring]                  Find Jira hosts in ~/.netrc
+
+Webhooks (no host needed):
+  webhook-listen [--addr :8080] [--secret S] [--out file.jsonl] [--exec "cmd"] [--exec-timeout 30s]
+                                        Receive Jira webhooks, emit normalized JSONL
+  webhook-listen --replay payload.json...
+                                        Normalize recorded payloads and exit
+
+Commands (first arg is hostname or substring matching ~/.netrc):
   <host> whoami                         Show current user
   <host> test                           Test connection
   <host> recent [limit]                 Recently updated issues
   <host> my-issues [limit]              Issues assigned to you
   <host> watched [limit]                Unresolved watched issues
   <host> watch-changes [days]           Watched issues updated recently (default: 7d)
+  <host> follow [JQL] [--interval 2m] [--state path]
+                                        New changes/comments since last run (JSONL)
   <host> search <JQL> [limit]           Search via JQL
+  <host> search @alias [k=v...] [limit] Search via a stored JQL alias
+  <host> similar "<summary>" [--project KEY] [--desc ...]
+                                        Rank existing issues by similarity
   <host> issue <key>                    Full issue details + description
   <host> issue-info <key>               Compact issue metadata (JSON)
   <host> comments <key> [limit]         Issue comments
@@ -1279,6 +3388,12 @@ Commands (first arg is hostname or substring matching ~/.netrc):
   <host> project-info <key>             Project details
   <host> statuses [project-key]         List statuses
   <host> filters                        Favourite/saved filters
+  <host> filter <id> [limit]            Run a saved filter
+  <host> report <JQL> --rows F --cols F [--sum F] [--format text|md|csv] [--per-project]
+                                        Pivot table of counts/sums with totals
+  <host> aliases                        List local JQL aliases
+  <host> alias-set <name> '<JQL>'       Save a JQL alias ({param} / {param=default})
+  <host> alias-rm <name>                Remove a JQL alias
   <host> boards                         List agile boards
   <host> sprints <board-id> [state]     List sprints (active|closed|future)
   <host> sprint-issues <sprint-id>      Issues in a sprint
@@ -1289,13 +3404,31 @@ Write commands (shared-state — treat as destructive):
                                         Flags: --type --epic --epic-field
                                         --assignee --priority --labels
                                         --desc --desc-file --desc-stdin
+                                        --check-duplicates --dup-threshold
+                                        --dup-action abort|warn
   <host> comment <key> [--body ... | --body-file path | --body-stdin]
                                         Add a comment to an issue.
                                         (Bodies render as plain text with
                                         issue-key auto-link on most instances;
                                         wiki markup/markdown stays literal.)
+                                        --visibility role:<name>|group:<name>
+                                        restricts who sees it; @user / @email
+                                        become mentions (--no-mentions to skip).
   <host> edit-comment <key> <comment-id> [--body ... | --body-file path | --body-stdin]
                                         Replace the body of an existing comment.
+                                        Accepts --visibility and --no-mentions.
+  <host> delete-comment <key> <comment-id>
+                                        Delete a comment.
+  <host> filter-create --name "..." --jql '...' [--description ...]
+                       [--share global|group:G|project:KEY]...
+                                        Save a filter (JQL validated first).
+  <host> filter-update <id> [--name ...] [--jql ...] [--description ...] [--share ...]...
+                                        Update a filter; --share replaces shares.
+  <host> clone <key> [--project OTHER] [--type T] [--with-subtasks] [--with-links] [--dry-run]
+                                        Copy an issue and link it to the original;
+                                        reports fields that could not be mapped.
+  <host> move <key> <project> [--type T]
+                                        Move an issue where the API allows it.
   <host> transition <key> <transition-id> [--comment "..."]
                                         Move an issue to a new status.
                                         Run 'transitions <key>' first for IDs.`)
@@ -1315,6 +3448,11 @@ func main() {
 		return
 	}
 
+	if args[0] == "webhook-listen" {
+		cmdWebhookListen(args[1:])
+		return
+	}
+
 	if len(args) < 2 {
 		printHelp()
 		return
@@ -1343,8 +3481,12 @@ func main() {
 		cmdWatched(client, cmdArgs)
 	case "watch-changes":
 		cmdWatchChanges(client, cmdArgs)
+	case "follow":
+		cmdFollow(client, cmdArgs)
 	case "search":
 		cmdSearch(client, cmdArgs)
+	case "similar":
+		cmdSimilar(client, cmdArgs)
 	case "issue":
 		cmdIssue(client, cmdArgs)
 	case "issue-info":
@@ -1363,6 +3505,20 @@ func main() {
 		cmdStatuses(client, cmdArgs)
 	case "filters":
 		cmdFilters(client)
+	case "filter":
+		cmdFilter(client, cmdArgs)
+	case "report":
+		cmdReport(client, cmdArgs)
+	case "aliases":
+		cmdAliases()
+	case "alias-set":
+		cmdAliasSet(client, cmdArgs)
+	case "alias-rm":
+		cmdAliasRm(cmdArgs)
+	case "filter-create":
+		cmdFilterCreate(client, cmdArgs)
+	case "filter-update":
+		cmdFilterUpdate(client, cmdArgs)
 	case "boards":
 		cmdBoards(client)
 	case "sprints":
@@ -1375,6 +3531,12 @@ func main() {
 		cmdComment(client, cmdArgs)
 	case "edit-comment":
 		cmdEditComment(client, cmdArgs)
+	case "delete-comment":
+		cmdDeleteComment(client, cmdArgs)
+	case "clone":
+		cmdClone(client, cmdArgs)
+	case "move":
+		cmdMove(client, cmdArgs)
 	case "transition":
 		cmdTransition(client, cmdArgs)
 	case "help":
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSecret(t *testing.T) {
+	body := readFixture(t, "issue_created.json")
+	tests := []struct {
+		name      string
+		secret    string
+		query     string
+		signature string
+		want      bool
+	}{
+		{"no secret configured", "", "", "", true},
+		{"valid signature", "s3cret", "", sign(body, "s3cret"), true},
+		{"signature without prefix", "s3cret", "", strings.TrimPrefix(sign(body, "s3cret"), "sha256="), true},
+		{"signature with wrong secret", "s3cret", "", sign(body, "other"), false},
+		{"signature over other body", "s3cret", "", sign([]byte("{}"), "s3cret"), false},
+		{"malformed signature", "s3cret", "", "sha256=not-hex", false},
+		{"bad signature is not rescued by query", "s3cret", "s3cret", sign(body, "other"), false},
+		{"valid query secret", "s3cret", "s3cret", "", true},
+		{"wrong query secret", "s3cret", "guess", "", false},
+		{"missing secret", "s3cret", "", "", false},
+	}
+	for _, tt := range tests {
+		target := "/"
+		if tt.query != "" {
+			target += "?secret=" + tt.query
+		}
+		r := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+		if tt.signature != "" {
+			r.Header.Set("X-Hub-Signature", tt.signature)
+		}
+		if got := verifyWebhookSecret(r, body, tt.secret); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeWebhook(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    webhookEvent
+	}{
+		{"issue_created.json", webhookEvent{
+			Timestamp: "2025-10-18T08:00:00Z",
+			Event:     "issue_created",
+			Issue:     "PROJ-42",
+			Summary:   "Login page returns 500 for SSO users",
+			Project:   "PROJ",
+			Status:    "Open",
+			User:      "alice",
+		}},
+		{"issue_updated.json", webhookEvent{
+			Timestamp: "2025-10-18T09:00:00Z",
+			Event:     "issue_updated",
+			Issue:     "PROJ-42",
+			Summary:   "Login page returns 500 for SSO users",
+			Project:   "PROJ",
+			Status:    "In Progress",
+			User:      "bob",
+			Changes: []webhookChange{
+				{Field: "status", From: "Open", To: "In Progress"},
+				{Field: "assignee", From: "", To: "Bob Baker"},
+			},
+		}},
+		{"comment_created.json", webhookEvent{
+			Timestamp: "2025-10-18T09:30:00Z",
+			Event:     "comment_created",
+			Issue:     "PROJ-42",
+			Summary:   "Login page returns 500 for SSO users",
+			Project:   "PROJ",
+			Status:    "In Progress",
+			User:      "carol", // no top-level user: falls back to the comment author
+			Comment: &webhookComment{
+				ID:     "30077",
+				Author: "carol",
+				Body:   "Reproduced on staging; the IdP returns an empty NameID.",
+			},
+		}},
+		{"sprint_started.json", webhookEvent{
+			Timestamp: "2025-10-20T07:00:00Z",
+			Event:     "sprint_started",
+			Sprint:    &webhookSprint{ID: "57", Name: "PROJ Sprint 14", State: "active"},
+		}},
+	}
+	for _, tt := range tests {
+		ev, err := normalizeWebhook(readFixture(t, tt.fixture))
+		if err != nil {
+			t.Errorf("%s: %v", tt.fixture, err)
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, ev.Received); err != nil {
+			t.Errorf("%s: received = %q: %v", tt.fixture, ev.Received, err)
+		}
+		ev.Received = ""
+		if !reflect.DeepEqual(*ev, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.fixture, *ev, tt.want)
+		}
+	}
+}
+
+func TestNormalizeWebhookRejects(t *testing.T) {
+	tests := []struct {
+		name, body, errText string
+	}{
+		{"invalid JSON", `{"webhookEvent":`, "invalid JSON"},
+		{"no event name", `{"issue":{"key":"PROJ-1"}}`, "missing webhookEvent"},
+		{"unsupported event", string(readFixture(t, "project_created.json")), "unsupported webhookEvent"},
+	}
+	for _, tt := range tests {
+		_, err := normalizeWebhook([]byte(tt.body))
+		if err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.errText)
+		}
+	}
+}
+
+func TestWebhookSinkJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	sink := newWebhookSink(&buf, "", 0)
+	fixtures := []string{"issue_created.json", "issue_updated.json", "comment_created.json", "sprint_started.json"}
+	for _, f := range fixtures {
+		ev, err := normalizeWebhook(readFixture(t, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.deliver(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.close()
+
+	var events []string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var ev webhookEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		events = append(events, ev.Event)
+	}
+	want := []string{"issue_created", "issue_updated", "comment_created", "sprint_started"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestWebhookSinkExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "exec.txt")
+	sink := newWebhookSink(nil, `printf '%s %s\n' "$JIRA_EVENT" "$JIRA_ISSUE" >> `+out, 5*time.Second)
+	for _, f := range []string{"issue_created.json", "comment_created.json"} {
+		ev, _ := normalizeWebhook(readFixture(t, f))
+		if err := sink.deliver(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.close()
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "issue_created PROJ-42\ncomment_created PROJ-42\n"; got != want {
+		t.Errorf("exec output = %q, want %q", got, want)
+	}
+}
+
+func TestWebhookSinkExecDoesNotBlock(t *testing.T) {
+	sink := newWebhookSink(nil, "exec sleep 10", 200*time.Millisecond)
+	ev, _ := normalizeWebhook(readFixture(t, "issue_created.json"))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := sink.deliver(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("deliver blocked for %s", d)
+	}
+	sink.close()
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("commands were not killed at the timeout (took %s)", d)
+	}
+}
+
+func TestWebhookSinkExecWaitsWhenReplaying(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "exec.txt")
+	sink := newWebhookSink(nil, "echo x >> "+out, 5*time.Second)
+	sink.wait = true
+	ev, _ := normalizeWebhook(readFixture(t, "issue_created.json"))
+	n := webhookQueueDepth + 10
+	for i := 0; i < n; i++ {
+		if err := sink.deliver(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.close()
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "x\n"); got != n {
+		t.Errorf("ran %d commands, want %d", got, n)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	body := readFixture(t, "issue_updated.json")
+	tests := []struct {
+		name      string
+		method    string
+		body      []byte
+		signature string
+		want      int
+		written   bool
+	}{
+		{"signed delivery", http.MethodPost, body, sign(body, "s3cret"), http.StatusNoContent, true},
+		{"bad signature", http.MethodPost, body, sign(body, "other"), http.StatusForbidden, false},
+		{"unsupported event", http.MethodPost, readFixture(t, "project_created.json"), sign(readFixture(t, "project_created.json"), "s3cret"), http.StatusBadRequest, false},
+		{"GET", http.MethodGet, nil, "", http.StatusMethodNotAllowed, false},
+		{"too large", http.MethodPost, bytes.Repeat([]byte(" "), webhookMaxBody+1), "", http.StatusRequestEntityTooLarge, false},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		h := newWebhookSink(&buf, "", 0).handler("s3cret")
+		r := httptest.NewRequest(tt.method, "/", bytes.NewReader(tt.body))
+		if tt.signature != "" {
+			r.Header.Set("X-Hub-Signature", tt.signature)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if written := buf.Len() > 0; written != tt.written {
+			t.Errorf("%s: written = %v, want %v (%q)", tt.name, written, tt.written, buf.String())
+		}
+	}
+}
+
+func TestResolveMentions(t *testing.T) {
+	users := map[string]string{
+		"jdoe":                 `[{"name":"jdoe2","displayName":"J Doe Two"},{"name":"jdoe","displayName":"J Doe"}]`,
+		"Override":             `[{"name":"overridden.user","displayName":"Only Hit"}]`,
+		"jane.doe@example.com": `[{"name":"jane","emailAddress":"jane.doe@example.com"}]`,
+		"JKEY":                 `[{"name":"someone","key":"jkey"}]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strOr(users[r.URL.Query().Get("username")], "[]"))
+	}))
+	defer srv.Close()
+	c := &apiClient{baseURL: srv.URL}
+
+	got, err := resolveMentions(c, "cc @jdoe, @jane.doe@example.com and @JKEY. @Override is fine; so is @ghost and me@example.com (@jdoe)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "cc [~jdoe], [~jane] and [~someone]. @Override is fine; so is @ghost and me@example.com ([~jdoe])"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestSearchUsersReportsBothErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("username") {
+			http.Error(w, `{"errorMessages":["username is not supported"]}`, http.StatusBadRequest)
+			return
+		}
+		http.Error(w, `{"errorMessages":["not authorized"]}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	_, err := searchUsers(&apiClient{baseURL: srv.URL}, "x")
+	if err == nil || !strings.Contains(err.Error(), "not supported") || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("err = %v, want both failures", err)
+	}
+}
+
+func TestPivotCountsDistinctIssues(t *testing.T) {
+	p := newPivot()
+	// PROJ-1 has two labels, so it fans out into two rows.
+	p.add("PROJ-1", "backend", "Open", 3)
+	p.add("PROJ-1", "frontend", "Open", 3)
+	p.add("PROJ-2", "backend", "Done", 5)
+	p.add("PROJ-2", "backend", "Done", 5) // duplicate value: ignored
+
+	want := [][]string{
+		{"label", "Done", "Open", "Total"},
+		{"backend", "5", "3", "8"},
+		{"frontend", "", "3", "3"},
+		{"Total", "5", "3", "8"},
+	}
+	if got := p.table("label"); !reflect.DeepEqual(got, want) {
+		t.Errorf("table = %q, want %q", got, want)
+	}
+	if !p.fannedOut {
+		t.Error("fannedOut = false, want true")
+	}
+}
+
+func TestTextTokens(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Fix the login page for SSO users", []string{"login", "page", "sso", "users"}},
+		{"HTTP 500 on /api/v2 (x)", []string{"http", "500", "api", "v2"}},
+		{"Größe der Übersicht falsch", []string{"größe", "der", "übersicht", "falsch"}},
+		{"Ошибка входа в систему", []string{"ошибка", "входа", "систему"}},
+		{"a I to", nil},
+	}
+	for _, tt := range tests {
+		if got := textTokens(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("textTokens(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCosineSim(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want float64
+	}{
+		{[]string{"login", "sso"}, []string{"sso", "login"}, 1},
+		{[]string{"login"}, []string{"export"}, 0},
+		{[]string{"login", "sso"}, []string{"login", "export"}, 0.5},
+		{[]string{"login", "login"}, []string{"login"}, 1},
+		{nil, []string{"login"}, 0},
+	}
+	for _, tt := range tests {
+		if got := cosineSim(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("cosineSim(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFindSimilarUsesRelevanceAndPages(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, q.Get("jql"))
+		start, _ := strconv.Atoi(q.Get("startAt"))
+		var issues []string
+		for i := start; i < start+100 && i < 150; i++ {
+			summary := "Unrelated login change"
+			if i == 149 {
+				summary = "Login page returns 500 for SSO users"
+			}
+			issues = append(issues, fmt.Sprintf(`{"key":"PROJ-%d","fields":{"summary":%q,"status":{"name":"Open"}}}`, i, summary))
+		}
+		fmt.Fprintf(w, `{"total":150,"issues":[%s]}`, strings.Join(issues, ","))
+	}))
+	defer srv.Close()
+
+	cands, err := findSimilar(&apiClient{baseURL: srv.URL}, "PROJ", "Login page returns 500 for SSO users", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 {
+		t.Errorf("made %d search requests, want 2", len(queries))
+	}
+	if strings.Contains(strings.ToUpper(queries[0]), "ORDER BY") {
+		t.Errorf("jql %q overrides relevance ordering", queries[0])
+	}
+	if len(cands) != 150 || cands[0].Key != "PROJ-149" {
+		t.Errorf("got %d candidates, best %v; want 150 with PROJ-149 first", len(cands), cands[0])
+	}
+}